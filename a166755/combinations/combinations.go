@@ -80,6 +80,32 @@ func EnumerateNonemptySubsets(config IndicatorConfig, elements []int, zero int,
 	close(out)
 }
 
+// Enumerate all assignments of the choices to elements, in which at
+// least one element is given the mandatory value, to a channel.
+func EnumerateWithValue(config IndicatorConfig, elements []int, value int, choices []int, out chan<- IndicatorMap) {
+	var recurse func([]int, bool)
+	chosen := NewIndicatorMap(config)
+	recurse = func(remaining []int, valuePresent bool) {
+		first := remaining[0]
+		last := len(remaining) == 1
+		for _, c := range choices {
+			present := valuePresent || c == value
+			// The last element is our last chance to use the value.
+			if last && !present {
+				continue
+			}
+			chosen.Set(first, c)
+			if last {
+				out <- copyMap(chosen)
+			} else {
+				recurse(remaining[1:], present)
+			}
+		}
+	}
+	recurse(elements, false)
+	close(out)
+}
+
 type SetGenerator interface {
 	Config() IndicatorConfig
 	Enumerate(out chan<- IndicatorMap)
@@ -129,6 +155,43 @@ func (f *FreeChoice) Config() IndicatorConfig {
 	return f.C
 }
 
+// MandatoryValue generalizes MandatoryZero and MandatoryOne to
+// more than two colors.
+type MandatoryValue struct {
+	C       IndicatorConfig
+	Set     []int
+	Value   int
+	Choices []int
+}
+
+func (m *MandatoryValue) Enumerate(out chan<- IndicatorMap) {
+	EnumerateWithValue(m.C, m.Set, m.Value, m.Choices, out)
+}
+
+func (m *MandatoryValue) Config() IndicatorConfig {
+	return m.C
+}
+
+// FreeValue generalizes FreeChoice to more than two colors.
+type FreeValue struct {
+	C       IndicatorConfig
+	Index   int
+	Choices []int
+}
+
+func (f *FreeValue) Enumerate(out chan<- IndicatorMap) {
+	chosen := NewIndicatorMap(f.C)
+	for _, c := range f.Choices {
+		chosen.Set(f.Index, c)
+		out <- copyMap(chosen)
+	}
+	close(out)
+}
+
+func (f *FreeValue) Config() IndicatorConfig {
+	return f.C
+}
+
 func Product(sets []SetGenerator, out chan<- IndicatorMap) {
 	chosen := NewIndicatorMap(sets[0].Config())
 	ProductWithPrefix(chosen, sets, out)
@@ -146,8 +209,27 @@ func ProductList(sets []SetGenerator) []IndicatorMap {
 }
 
 func ProductWithPrefix(chosen IndicatorMap, sets []SetGenerator, out chan<- IndicatorMap) {
+	ProductWithPrefixUntil(chosen, sets, out, nil)
+}
+
+// ProductWithPrefixUntil is like ProductWithPrefix, but stops sending
+// once done is closed.  It never closes out.
+func ProductWithPrefixUntil(chosen IndicatorMap, sets []SetGenerator, out chan<- IndicatorMap, done <-chan struct{}) {
 	var recurse func(int)
+	stopped := false
+	send := func() {
+		select {
+		case out <- copyMap(chosen):
+		case <-done:
+			stopped = true
+		}
+	}
 
+	if len(sets) == 0 {
+		// Nothing left to choose, the prefix is complete.
+		send()
+		return
+	}
 	config := sets[0].Config()
 
 	recurse = func(i int) {
@@ -158,6 +240,11 @@ func ProductWithPrefix(chosen IndicatorMap, sets []SetGenerator, out chan<- Indi
 		go sets[i].Enumerate(ch)
 
 		for subMap := range ch {
+			// Keep draining ch once stopped, so the set's goroutine
+			// can finish.
+			if stopped {
+				continue
+			}
 			// Copy in just the values that were chosen.
 			for i, p := range subMap.Present {
 				if p {
@@ -165,14 +252,12 @@ func ProductWithPrefix(chosen IndicatorMap, sets []SetGenerator, out chan<- Indi
 				}
 			}
 			if i == 0 {
-				out <- copyMap(chosen)
+				send()
 			} else {
 				recurse(i - 1)
 			}
 		}
 	}
 
-	if len(sets) > 0 {
-		recurse(len(sets) - 1)
-	}
+	recurse(len(sets) - 1)
 }
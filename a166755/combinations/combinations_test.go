@@ -75,3 +75,92 @@ func TestProduct_Example(t *testing.T) {
 		t.Fatalf("bad count, got %v", count)
 	}
 }
+
+func TestEnumerateWithValue_Example(t *testing.T) {
+	out := make(chan IndicatorMap)
+	go EnumerateWithValue(
+		IndicatorConfig{4, 0},
+		[]int{0, 1, 2},
+		2, []int{0, 2, 3},
+		out)
+
+	count := 0
+	for x := range out {
+		count += 1
+		t.Logf("output %d: %v", count, x)
+		if x.Present[3] {
+			t.Fatal("element outside set is present")
+		}
+		foundValue := false
+		for _, v := range x.Values[:3] {
+			switch v {
+			case 2:
+				foundValue = true
+			case 0, 3:
+			default:
+				t.Fatal("element not among choices")
+			}
+		}
+		if !foundValue {
+			t.Fatal("mandatory value not present")
+		}
+	}
+	// 3^3 assignments, less the 2^3 which do not use the value
+	if count != 27-8 {
+		t.Fatalf("expected 19 assignments, got %d", count)
+	}
+}
+
+func TestProduct_Values(t *testing.T) {
+	config := IndicatorConfig{5, 0}
+	choices := []int{0, 1, 2}
+	a := &MandatoryValue{config, []int{0, 1}, 1, choices}
+	b := &FreeValue{config, 2, choices}
+	c := &MandatoryValue{config, []int{3, 4}, 0, choices}
+
+	out := make(chan IndicatorMap)
+	go Product([]SetGenerator{a, b, c}, out)
+
+	count := 0
+	for x := range out {
+		count += 1
+		if x.Values[0] != 1 && x.Values[1] != 1 {
+			t.Fatal("no ones for indices 0,1")
+		}
+		if x.Values[3] != 0 && x.Values[4] != 0 {
+			t.Fatal("no zeros for indices 3,4")
+		}
+		for _, p := range x.Present {
+			if !p {
+				t.Fatal("value not present")
+			}
+		}
+	}
+
+	if count != 5*3*5 {
+		t.Fatalf("bad count, got %v", count)
+	}
+}
+
+func TestProductWithPrefixUntil_Stops(t *testing.T) {
+	config := IndicatorConfig{40, 0}
+	sets := make([]SetGenerator, 40)
+	for i := range sets {
+		sets[i] = &FreeChoice{config, i}
+	}
+
+	out := make(chan IndicatorMap)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		ProductWithPrefixUntil(NewIndicatorMap(config), sets, out, done)
+		close(finished)
+	}()
+
+	for i := 0; i < 10; i++ {
+		<-out
+	}
+	close(done)
+	// 2^40 products would never finish
+	<-finished
+}
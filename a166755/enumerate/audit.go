@@ -0,0 +1,233 @@
+package enumerate
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// The totals of two methods may agree even if the counts of some of
+// their classes are wrong.  auditEnumeration classifies every small
+// grid directly, by depth-first search, and compares the number of
+// grids in each class with the counts kept by -square and by the
+// rectangle enumeration.
+//
+// Both only keep grids which could still be finished with two regions:
+// every component reaches the edge, except perhaps one which is the
+// only component of its color.  The rectangle enumeration also
+// discards first rows with more than three runs; see startingClasses.
+
+// forEachGrid calls f with every width x height grid of two colors.
+// The grid is only valid until f returns.
+func forEachGrid(width int, height int, f func(grid [][]int)) {
+	grid := make([][]int, height)
+	for y := range grid {
+		grid[y] = make([]int, width)
+	}
+	for bits := uint64(0); bits < 1<<uint(width*height); bits++ {
+		for y := range grid {
+			for x := range grid[y] {
+				grid[y][x] = int(bits>>uint(y*width+x)) & 1
+			}
+		}
+		f(grid)
+	}
+}
+
+// viableGrid checks whether every component of the grid reaches the
+// edge, except perhaps one which is the only component of its color.
+func viableGrid(board equiv.Board, grid [][]int, onEdge func(c equiv.Coord) bool) bool {
+	colors := make([]int, board.Width*board.Height)
+	for y := range grid {
+		for x := range grid[y] {
+			colors[y*board.Width+x] = grid[y][x]
+		}
+	}
+	visited := make([]bool, len(colors))
+	var byColor [2]int
+	numClosed := 0
+	closedColor := 0
+	for y := 1; y <= board.Height; y++ {
+		for x := 1; x <= board.Width; x++ {
+			component := board.ConnectedComponent(colors, equiv.Coord{x, y}, visited)
+			if len(component) == 0 {
+				continue
+			}
+			color := colors[board.Index(component[0])]
+			byColor[color] += 1
+			closed := true
+			for _, c := range component {
+				if onEdge(c) {
+					closed = false
+					break
+				}
+			}
+			if closed {
+				numClosed += 1
+				closedColor = color
+			}
+		}
+	}
+	return numClosed == 0 || (numClosed == 1 && byColor[closedColor] == 1)
+}
+
+// numRuns counts the runs of a single color in a row.
+func numRuns(row []int) int {
+	runs := 1
+	for x := 1; x < len(row); x++ {
+		if row[x] != row[x-1] {
+			runs += 1
+		}
+	}
+	return runs
+}
+
+// classAudit is the number of grids found in each class, with a
+// drawing of the class.
+type classAudit struct {
+	Counts map[ClassKey]*big.Int
+	Plots  map[ClassKey]string
+}
+
+func newClassAudit() *classAudit {
+	return &classAudit{
+		Counts: make(map[ClassKey]*big.Int),
+		Plots:  make(map[ClassKey]string),
+	}
+}
+
+func (a *classAudit) Add(key ClassKey, plot func() string, n *big.Int) {
+	if exist, ok := a.Counts[key]; ok {
+		exist.Add(exist, n)
+		return
+	}
+	a.Counts[key] = new(big.Int).Set(n)
+	a.Plots[key] = plot()
+}
+
+// squareAudit classifies every n x n grid which -square keeps.
+func squareAudit(n int) *classAudit {
+	a := newClassAudit()
+	one := big.NewInt(1)
+	board := equiv.Board{Width: n, Height: n}
+	onEdge := func(c equiv.Coord) bool {
+		return c.X == n || c.Y == n
+	}
+	forEachGrid(n, n, func(grid [][]int) {
+		if !viableGrid(board, grid, onEdge) {
+			return
+		}
+		gb := equiv.EdgeClassForGrid(n, grid)
+		gb.MakeCanonical()
+		a.Add(keyOf(gb), gb.Plot, one)
+	})
+	return a
+}
+
+// rectangleAudit classifies every width x height grid which the
+// rectangle enumeration keeps.
+func rectangleAudit(width int, height int) *classAudit {
+	a := newClassAudit()
+	one := big.NewInt(1)
+	board := equiv.Board{Width: width, Height: height}
+	onEdge := func(c equiv.Coord) bool {
+		return c.Y == height
+	}
+	forEachGrid(width, height, func(grid [][]int) {
+		if numRuns(grid[0]) > 3 || !viableGrid(board, grid, onEdge) {
+			return
+		}
+		gr := equiv.RectangleClassForGrid(width, height, grid)
+		gr.MakeCanonical()
+		a.Add(keyOf(gr), gr.Plot, one)
+	})
+	return a
+}
+
+// compareAudits writes every class whose count differs between the
+// grids classified directly and those counted by a method to out, and
+// returns false if there are any.
+func compareAudits(out io.Writer, label string, expected *classAudit, actual *classAudit) bool {
+	keys := make([]ClassKey, 0, len(expected.Counts))
+	for k := range expected.Counts {
+		keys = append(keys, k)
+	}
+	for k := range actual.Counts {
+		if _, ok := expected.Counts[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Less(keys[j])
+	})
+
+	zero := big.NewInt(0)
+	numDiffer := 0
+	for _, k := range keys {
+		e, ok := expected.Counts[k]
+		if !ok {
+			e = zero
+		}
+		a, ok := actual.Counts[k]
+		if !ok {
+			a = zero
+		}
+		if e.Cmp(a) == 0 {
+			continue
+		}
+		if numDiffer == 0 {
+			fmt.Fprintf(out, "**** %v | MISMATCH\n\n", label)
+		}
+		numDiffer += 1
+		plot := expected.Plots[k]
+		if plot == "" {
+			plot = actual.Plots[k]
+		}
+		fmt.Fprintf(out, "%v  %v | grids=%v | counted=%v\n\n", plot, k, e, a)
+	}
+	if numDiffer == 0 {
+		fmt.Fprintf(out, "**** %v | classes=%d | ok\n", label, len(keys))
+	}
+	return numDiffer == 0
+}
+
+// AuditEnumeration audits -square for every size up to max, and the
+// rectangle enumeration for every width and height up to max, writing
+// the results to out.  It returns false if any class count differs.
+func AuditEnumeration(out io.Writer, max int, opts Options) (bool, error) {
+	if max < 1 {
+		return false, fmt.Errorf("AuditEnumeration needs a size of at least 1, not %d", max)
+	}
+	if err := opts.checkTwoColorMethod("AuditEnumeration"); err != nil {
+		return false, err
+	}
+	ok := true
+	check := func(ec *EquivalenceClasses) {
+		actual := newClassAudit()
+		for k, n := range ec.CountByClass {
+			actual.Add(k, ec.Classes[k].Plot, new(big.Int).SetUint64(n))
+		}
+		label := fmt.Sprintf("square N=%d", ec.Size)
+		ok = compareAudits(out, label, squareAudit(ec.Size), actual) && ok
+	}
+	check(InitEquivalenceClasses())
+	runEquivalenceClasses(max, opts, check)
+
+	for width := 1; width <= max; width++ {
+		s := NewSuccessorMap(twoColorTransfer{}, width, opts)
+		s.KeepClasses()
+		s.Run(max, func(r HeightResult) {
+			actual := newClassAudit()
+			for k, v := range s.CountByClass {
+				c, _ := s.Classes.Load(k)
+				actual.Add(k, c.(RowClass).Plot, v.Count)
+			}
+			label := fmt.Sprintf("rectangle %v", Dimensions{r.Width, r.Height})
+			ok = compareAudits(out, label, rectangleAudit(r.Width, r.Height), actual) && ok
+		})
+	}
+	return ok, nil
+}
@@ -0,0 +1,221 @@
+package enumerate
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// A checkpoint saves a SuccessorMap after some height, so that a long
+// enumeration can continue from there instead of starting over.  It is
+// written with encoding/gob: first a checkpointHeader, so that files
+// from another version are rejected before anything else is read, and
+// then the checkpoint itself.
+
+const checkpointVersion = 2
+
+type checkpointHeader struct {
+	Format  string
+	Version int
+}
+
+// keyedClass is one entry of a map from ClassKey to RowClass.
+type keyedClass struct {
+	Key   ClassKey
+	Class RowClass
+}
+
+// keyedSuccessors is one entry of SuccessorCounts.
+type keyedSuccessors struct {
+	Key        ClassKey
+	Successors []EdgeClass
+}
+
+type checkpoint struct {
+	// Describes the transfer, which must match when resuming
+	Transfer string
+
+	Width  int
+	Height int
+
+	// Every result so far, by height, starting with 1
+	Results []HeightResult
+
+	CountByClass    []EdgeClass
+	SuccessorCounts []keyedSuccessors
+	NewClasses      []keyedClass
+	ValidClasses    []ClassKey
+	Classes         []keyedClass
+
+	Moduli   []uint64
+	Residues []map[ClassKey]uint64
+}
+
+func init() {
+	gob.Register(&equiv.GridRectangle{})
+	gob.Register(&equiv.ColorRectangle{})
+}
+
+// describeTransfer identifies a transfer and its options.
+func describeTransfer(t Transfer) string {
+	switch t := t.(type) {
+	case colorTransfer:
+		rules := *t.Rules
+		t.Rules = nil
+		return fmt.Sprintf("%T %+v %+v", t, t, rules)
+	case regionTransfer:
+		rules := *t.Rules
+		t.Rules = nil
+		return fmt.Sprintf("%T %+v %+v", t, t, rules)
+	}
+	return fmt.Sprintf("%T %+v", t, t)
+}
+
+// rulesOf returns the Rules shared by the classes of a transfer, if any.
+func rulesOf(t Transfer) *equiv.Rules {
+	switch t := t.(type) {
+	case colorTransfer:
+		return t.Rules
+	case regionTransfer:
+		return t.Rules
+	}
+	return nil
+}
+
+// newCheckpoint captures the state of the map, which has just
+// finished the last of the results given.
+func newCheckpoint(s *SuccessorMap, results []HeightResult) *checkpoint {
+	c := &checkpoint{
+		Transfer: describeTransfer(s.Transfer),
+		Width:    s.Width,
+		Height:   s.Height,
+		Results:  results,
+		Moduli:   s.Moduli,
+		Residues: s.Residues,
+	}
+	for _, v := range s.CountByClass {
+		c.CountByClass = append(c.CountByClass, v)
+	}
+	s.SuccessorCounts.Range(func(k, v interface{}) bool {
+		c.SuccessorCounts = append(c.SuccessorCounts, keyedSuccessors{k.(ClassKey), v.([]EdgeClass)})
+		return true
+	})
+	for _, v := range s.NewClasses {
+		c.NewClasses = append(c.NewClasses, keyedClass{keyOf(v), v})
+	}
+	s.ValidClasses.Range(func(k, v interface{}) bool {
+		c.ValidClasses = append(c.ValidClasses, k.(ClassKey))
+		return true
+	})
+	s.Classes.Range(func(k, v interface{}) bool {
+		c.Classes = append(c.Classes, keyedClass{k.(ClassKey), v.(RowClass)})
+		return true
+	})
+	return c
+}
+
+// Restore rebuilds the map, which continues with transfer t.
+func (c *checkpoint) Restore(t Transfer, opts Options) (*SuccessorMap, error) {
+	if describeTransfer(t) != c.Transfer {
+		return nil, fmt.Errorf("checkpoint was made with other options: %v", c.Transfer)
+	}
+	s := &SuccessorMap{
+		Width:       c.Width,
+		Height:      c.Height,
+		Transfer:    t,
+		Options:     opts,
+		NewClasses:  make([]RowClass, 0, len(c.NewClasses)),
+		keepClasses: needsClasses(t, opts),
+	}
+
+	// Each class was decoded with its own copy of the rules.
+	rules := rulesOf(t)
+	share := func(v RowClass) RowClass {
+		if cr, ok := v.(*equiv.ColorRectangle); ok && rules != nil {
+			cr.Rules = rules
+		}
+		return v
+	}
+
+	if len(c.Moduli) > 0 {
+		s.Moduli = c.Moduli
+		s.Residues = c.Residues
+		s.modSuccessors = make(map[ClassKey][]modSuccessor)
+	} else {
+		s.CountByClass = make(map[ClassKey]EdgeClass, len(c.CountByClass))
+		for _, v := range c.CountByClass {
+			if v.Class != nil {
+				v.Class = share(v.Class)
+			}
+			s.CountByClass[v.Key] = v
+		}
+	}
+	for _, v := range c.SuccessorCounts {
+		s.SuccessorCounts.Store(v.Key, v.Successors)
+	}
+	for _, v := range c.NewClasses {
+		s.NewClasses = append(s.NewClasses, share(v.Class))
+	}
+	for _, k := range c.ValidClasses {
+		s.ValidClasses.Store(k, struct{}{})
+	}
+	for _, v := range c.Classes {
+		s.Classes.Store(v.Key, share(v.Class))
+	}
+	return s, nil
+}
+
+// saveCheckpoint writes the checkpoint, replacing the file only once
+// it is complete.
+func saveCheckpoint(filename string, c *checkpoint) error {
+	tmp := filename + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(f)
+	err = enc.Encode(checkpointHeader{"a166755", checkpointVersion})
+	if err == nil {
+		err = enc.Encode(c)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+func loadCheckpoint(filename string) (*checkpoint, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+
+	var header checkpointHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Format != "a166755" {
+		return nil, fmt.Errorf("not a checkpoint")
+	}
+	if header.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint version %d, expected %d", header.Version, checkpointVersion)
+	}
+	c := &checkpoint{}
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// checkpointName is the file written for each width.
+func checkpointName(prefix string, width int) string {
+	return fmt.Sprintf("%v.%d", prefix, width)
+}
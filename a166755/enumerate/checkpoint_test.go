@@ -0,0 +1,80 @@
+package enumerate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+func TestCheckpoint_Resume(t *testing.T) {
+	ctx := context.Background()
+	three := &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square}
+	cases := []struct {
+		Name string
+		Opts Options
+	}{
+		{"two colors", Options{}},
+		{"three colors", Options{Rules: three}},
+		{"modular", Options{Modular: true}},
+	}
+	for _, c := range cases {
+		prefix := filepath.Join(t.TempDir(), "checkpoint")
+		saving := c.Opts
+		saving.Checkpoint = prefix
+		if _, _, err := CountRectangle(ctx, 4, 5, saving); err != nil {
+			t.Fatalf("%v: %v", c.Name, err)
+		}
+
+		resuming := c.Opts
+		resuming.Resume = checkpointName(prefix, 4)
+		for _, height := range []int{4, 5, 6} {
+			count, _, err := CountRectangle(ctx, 4, height, resuming)
+			if err != nil {
+				t.Fatalf("%v 4x%d: %v", c.Name, height, err)
+			}
+			expected, _, err := CountRectangle(ctx, 4, height, c.Opts)
+			if err != nil {
+				t.Fatalf("%v 4x%d: %v", c.Name, height, err)
+			}
+			if count.Cmp(expected) != 0 {
+				t.Errorf("%v 4x%d: resumed %v, expected %v", c.Name, height, count, expected)
+			}
+		}
+	}
+}
+
+func TestCheckpoint_Mismatch(t *testing.T) {
+	ctx := context.Background()
+	prefix := filepath.Join(t.TempDir(), "checkpoint")
+	if _, _, err := CountRectangle(ctx, 4, 4, Options{Checkpoint: prefix}); err != nil {
+		t.Fatal(err)
+	}
+	filename := checkpointName(prefix, 4)
+
+	three := &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square}
+	cases := []struct {
+		Name   string
+		Width  int
+		Resume Options
+	}{
+		{"width", 5, Options{Resume: filename}},
+		{"rules", 4, Options{Resume: filename, Rules: three}},
+		{"interface", 4, Options{Resume: filename, Weights: Weights{Interface: true}}},
+		{"modular", 4, Options{Resume: filename, Modular: true}},
+		{"missing", 4, Options{Resume: filename + ".missing"}},
+	}
+	for _, c := range cases {
+		if _, _, err := CountRectangle(ctx, c.Width, 6, c.Resume); err == nil {
+			t.Errorf("expected an error resuming with another %v", c.Name)
+		}
+	}
+}
+
+func TestCheckpoint_SaveError(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "missing", "checkpoint")
+	if _, _, err := CountRectangle(context.Background(), 4, 4, Options{Checkpoint: prefix}); err == nil {
+		t.Errorf("expected an error saving to a missing directory")
+	}
+}
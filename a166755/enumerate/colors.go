@@ -0,0 +1,161 @@
+package enumerate
+
+import (
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// colorTransfer counts k-colored grids in which every Connected color
+// is used, and each forms a single connected region.  Unrestricted
+// colors may form any number of regions, and Absent ones are not used.
+type colorTransfer struct {
+	// Rules.CountRegions must not be set.
+	Rules *equiv.Rules
+
+	// Whether to count the grids by the length of their interface.
+	CountInterface bool
+}
+
+func (t colorTransfer) StartingClasses(width int) map[ClassKey]EdgeClass {
+	// Every possible first row is an expansion of the empty grid.
+	byKey := make(map[ClassKey]EdgeClass)
+	for _, e := range EnumerateColorChildren(equiv.NewColorRectangle(width, t.Rules), t.Weights()) {
+		byKey[e.Key] = e
+	}
+	return byKey
+}
+
+func (t colorTransfer) Weights() Weights {
+	return Weights{Cells: t.Rules.CountBlack, Interface: t.CountInterface}
+}
+
+func (t colorTransfer) Children(c RowClass) []EdgeClass {
+	return EnumerateColorChildren(c.(*equiv.ColorRectangle), t.Weights())
+}
+
+func (t colorTransfer) IsValid(c RowClass) bool {
+	cr := c.(*equiv.ColorRectangle)
+	numEdge := 0
+	for c, n := range cr.GluedSets() {
+		if cr.Rules.ConstraintOf(c) != equiv.Connected {
+			continue
+		}
+		switch n {
+		case 0:
+		case 1:
+			numEdge += 1
+		default:
+			return false
+		}
+	}
+	return numEdge+cr.Regions == cr.Rules.NumConnected()
+}
+
+// EnumerateColorChildren returns the classes which follow cr, each
+// counted with the given weights.
+func EnumerateColorChildren(cr *equiv.ColorRectangle, weights Weights) []EdgeClass {
+	config := combinations.IndicatorConfig{
+		Size:   cr.Width,
+		Offset: 0,
+	}
+	prev := cr.EdgeColors()
+	byKey := make(map[ClassKey]EdgeClass)
+	add := func(child *equiv.ColorRectangle, row []int) {
+		key := keyOf(child)
+		n := weights.Row(cr.Rules, prev, row)
+		if exist, ok := byKey[key]; ok {
+			byKey[key] = exist.Inc(n)
+		} else {
+			byKey[key] = NewEdgeClass(key, child, n)
+		}
+	}
+
+	// A Connected color with a single component on the edge may be
+	// closed off, as long as it never appears again.  Any other set of
+	// a Connected color must be extended to the new row, or it will be
+	// left disconnected.  Sets of Unrestricted colors may end anywhere.
+	// On a torus, sets which touch the first row might still be
+	// connected at the end, so they need not be extended.
+	//
+	// An 8-connected set, or one on another lattice, may be extended
+	// diagonally, so instead of choosing one of its cells, we check
+	// afterward that no more sets were finished than the ones closed off.
+	inFirstRow := func(s equiv.EdgeSet) bool {
+		return s[len(s)-1] >= cr.Width
+	}
+	closable := make([]int, 0)
+	for c := range cr.Colors {
+		if cr.Rules.ConstraintOf(c) != equiv.Connected {
+			continue
+		}
+		if len(cr.Colors[c].Sets) == 1 && !inFirstRow(cr.Colors[c].Sets[0]) {
+			closable = append(closable, c)
+		}
+	}
+
+	for mask := 0; mask < 1<<len(closable); mask++ {
+		closing := make([]bool, cr.Rules.NumColors)
+		for i, c := range closable {
+			if mask&(1<<i) != 0 {
+				closing[c] = true
+			}
+		}
+
+		// Connected colors on the edge, and those never used, are
+		// available, as are the Unrestricted colors.
+		choices := make([]int, 0, cr.Rules.NumColors)
+		for c := range cr.Colors {
+			switch cr.Rules.ConstraintOf(c) {
+			case equiv.Connected:
+				if !closing[c] && !cr.Closed[c] {
+					choices = append(choices, c)
+				}
+			case equiv.Unrestricted:
+				choices = append(choices, c)
+			}
+		}
+		if len(choices) == 0 {
+			continue
+		}
+
+		covered := make([]bool, cr.Width)
+		gens := make([]combinations.SetGenerator, 0, cr.Width)
+		numClosing := 0
+		for c := range cr.Colors {
+			if closing[c] {
+				numClosing += 1
+			}
+			for _, s := range cr.Colors[c].Sets {
+				if closing[c] || inFirstRow(s) || !cr.Rules.DirectlyAbove(c) ||
+					cr.Rules.ConstraintOf(c) != equiv.Connected {
+					continue
+				}
+				for _, pos := range s {
+					covered[pos] = true
+				}
+				gens = append(gens, &combinations.MandatoryValue{C: config, Set: s, Value: c, Choices: choices})
+			}
+		}
+		for pos := range covered {
+			if !covered[pos] {
+				gens = append(gens, &combinations.FreeValue{C: config, Index: pos, Choices: choices})
+			}
+		}
+
+		ch := make(chan combinations.IndicatorMap)
+		go combinations.Product(gens, ch)
+
+		for row := range ch {
+			child := cr.Expand(row.Values)
+			if child.Regions == cr.Regions+numClosing {
+				add(child, row.Values)
+			}
+		}
+	}
+
+	result := make([]EdgeClass, 0, len(byKey))
+	for _, v := range byKey {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,390 @@
+package enumerate
+
+// Counts of the grids with exactly two regions, as in A166755, and of
+// the other grids described by equiv.Rules.  There are three methods:
+//
+//   * CountRectangle adds one row at a time to a rectangle, keeping
+//     the number of grids in each class of its last row
+//   * CountSquare expands an n x n square by one row and column at a
+//     time, keeping the number of grids in each class of its border
+//   * CountExhaustive checks every grid
+//
+// Each is given Options, which say which grids to count, and how.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// DefaultNumWorkers is the number of goroutines used if
+// Options.NumWorkers is not set.
+const DefaultNumWorkers = 8
+
+// Options describe the grids being counted, and how to count them.
+// The zero value counts the grids of A166755.
+type Options struct {
+	// The number of goroutines expanding classes, or checking grids
+	NumWorkers int
+
+	// Write every class to Progress as it is expanded
+	Verbose bool
+
+	// If not nil, a line is written here as each height is counted.
+	Progress io.Writer
+
+	// The grids to count; if nil, those with one region of each of
+	// two colors on a plain square grid.  With Rules.CountRegions,
+	// every color must be Unrestricted.
+	Rules *equiv.Rules
+
+	// What to count the grids by, besides their number
+	Weights Weights
+
+	// With Rules.CountRegions, discard grids with more regions than
+	// this, if it is not zero.
+	MaxRegions int
+
+	// Only for the exhaustive enumeration, count the grids fixed by
+	// each element of Symmetries.
+	Symmetric bool
+
+	// The rest only apply to the rectangle enumeration; the other
+	// Enumeration functions return an error if they are set.
+
+	// Count with the broken-profile transfer; only for the grids of
+	// A166755.
+	Profile bool
+
+	// Count modulo several primes, and reconstruct the counts by the
+	// Chinese Remainder Theorem; see SuccessorMap.UseModuli.
+	Modular bool
+
+	// If not empty, keep the class counts and successors in sorted
+	// files in a temporary directory here; see SuccessorMap.UseDisk.
+	DiskDir string
+
+	// With DiskDir, the approximate number of bytes of counts or
+	// successors to hold in memory.
+	MemoryBudget int
+
+	// If not empty, save the state of the enumeration after each
+	// height, to this name with the width appended.
+	Checkpoint string
+
+	// If not empty, continue from this checkpoint.
+	Resume string
+}
+
+func (o Options) numWorkers() int {
+	if o.NumWorkers < 1 {
+		return DefaultNumWorkers
+	}
+	return o.NumWorkers
+}
+
+// progressf writes a line to Progress, if it is set.
+func (o Options) progressf(format string, a ...interface{}) {
+	if o.Progress != nil {
+		fmt.Fprintf(o.Progress, format, a...)
+	}
+}
+
+// verbosef writes to Progress, if it is set and Verbose is true.
+func (o Options) verbosef(format string, a ...interface{}) {
+	if o.Verbose {
+		o.progressf(format, a...)
+	}
+}
+
+// rules returns the Rules, with CountBlack set if the black cells
+// are being counted.
+func (o Options) rules() *equiv.Rules {
+	rules := o.Rules
+	if rules == nil {
+		rules = squareRules
+	}
+	if rules.CountBlack != o.Weights.Cells {
+		copied := *rules
+		copied.CountBlack = o.Weights.Cells
+		rules = &copied
+	}
+	return rules
+}
+
+// TwoColors reports whether the grids counted are those of A166755:
+// exactly two regions, one of each of two 4-connected colors, on a
+// plain square grid, without Weights.
+func (o Options) TwoColors() bool {
+	rules := o.rules()
+	return rules.NumColors == 2 && rules.Boundary == equiv.Plain && rules.Lattice == equiv.Square &&
+		rules.AdjacencyOf(0) == equiv.Four && rules.AdjacencyOf(1) == equiv.Four &&
+		rules.NumConnected() == 2 && !o.Weights.Any()
+}
+
+// transfer chooses the Transfer for the rectangle enumeration.
+func (o Options) transfer() Transfer {
+	// The two-color classes assume a square lattice, on which black
+	// and white are interchangeable, and both connected.  Once they
+	// are interchanged, the black cells can no longer be counted.
+	rules := o.rules()
+	switch {
+	case rules.CountRegions:
+		return regionTransfer{Rules: rules, MaxRegions: o.MaxRegions, CountInterface: o.Weights.Interface}
+	case rules.NumColors != 2 || rules.Boundary != equiv.Plain || rules.Lattice != equiv.Square ||
+		!rules.Interchangeable(0, 1) || rules.NumConnected() != 2:
+		return colorTransfer{Rules: rules, CountInterface: o.Weights.Interface}
+	default:
+		return twoColorTransfer{Adjacency: rules.AdjacencyOf(0), CountInterface: o.Weights.Interface}
+	}
+}
+
+// checkRules returns an error if the Rules cannot be counted, or not
+// by the Weights.
+func (o Options) checkRules() error {
+	rules := o.rules()
+	if rules.NumColors < 1 {
+		return fmt.Errorf("Rules need at least one color")
+	}
+	for c := 0; c < rules.NumColors; c++ {
+		if rules.Lattice != equiv.Square && rules.AdjacencyOf(c) != equiv.Four {
+			return fmt.Errorf("Adjacency only applies to the square lattice")
+		}
+		if rules.CountRegions && rules.ConstraintOf(c) != equiv.Unrestricted {
+			return fmt.Errorf("CountRegions needs every color to be Unrestricted")
+		}
+	}
+	switch {
+	case rules.Lattice == equiv.Cubic && rules.Boundary != equiv.Plain:
+		return fmt.Errorf("the cubic lattice only supports a plain boundary")
+	case o.Weights.Cells && rules.NumColors < 2:
+		return fmt.Errorf("Weights.Cells needs at least two colors")
+	case o.Weights.Interface && rules.Boundary == equiv.Torus:
+		return fmt.Errorf("Weights.Interface does not support the torus")
+	}
+	return nil
+}
+
+// checkTwoColors returns an error unless the grids counted are those
+// of A166755, for the methods which only count those.
+func (o Options) checkTwoColors(method string) error {
+	if !o.TwoColors() || o.rules().CountRegions {
+		return fmt.Errorf("%v only supports the grids of A166755", method)
+	}
+	return nil
+}
+
+// checkOther returns an error if the options cannot be used by a
+// method other than the rectangle enumeration, which may only count
+// the grids fixed by each symmetry if symmetric is set.
+func (o Options) checkOther(method string, symmetric bool) error {
+	if err := o.checkRules(); err != nil {
+		return err
+	}
+	switch {
+	case o.Profile || o.Modular || o.DiskDir != "" || o.Checkpoint != "" || o.Resume != "":
+		return fmt.Errorf("%v cannot be used with Profile, Modular, DiskDir, Checkpoint or Resume", method)
+	case o.Symmetric && !symmetric:
+		return fmt.Errorf("%v cannot be used with Symmetric", method)
+	case o.Symmetric:
+		return o.checkTwoColors("Symmetric")
+	}
+	return nil
+}
+
+// checkTwoColorMethod returns an error if the options cannot be used by
+// a method which only counts the grids of A166755, without Symmetric.
+func (o Options) checkTwoColorMethod(method string) error {
+	if err := o.checkOther(method, false); err != nil {
+		return err
+	}
+	return o.checkTwoColors(method)
+}
+
+// checkRectangle returns an error if the options cannot be used
+// together by the rectangle enumeration.
+func (o Options) checkRectangle() error {
+	if err := o.checkRules(); err != nil {
+		return err
+	}
+	counted := o.Weights.Any() || o.rules().CountRegions
+	switch {
+	case o.Symmetric:
+		return fmt.Errorf("Symmetric is only for the exhaustive enumeration")
+	case o.Profile && !o.TwoColors():
+		return fmt.Errorf("Profile only supports the grids of A166755")
+	case o.Profile && (o.Modular || o.DiskDir != "" || o.Checkpoint != "" || o.Resume != ""):
+		return fmt.Errorf("Profile cannot be used with Modular, DiskDir, Checkpoint or Resume")
+	case o.Modular && counted:
+		return fmt.Errorf("Modular does not support CountRegions or Weights")
+	case o.DiskDir != "" && (counted || o.Modular || o.Checkpoint != "" || o.Resume != ""):
+		return fmt.Errorf("DiskDir cannot be used with CountRegions, Weights, Modular, Checkpoint or Resume")
+	}
+	return nil
+}
+
+// checkSquares returns an error unless every case is a square, for
+// the methods which only count those.
+func checkSquares(method string, cases []Dimensions) error {
+	for _, d := range cases {
+		if !d.IsSquare() {
+			return fmt.Errorf("%v only supports square grids, not %v", method, d)
+		}
+	}
+	return nil
+}
+
+// Dimensions is the size of a rectangular grid to count.
+type Dimensions struct {
+	Width  int
+	Height int
+}
+
+func (d Dimensions) IsSquare() bool {
+	return d.Width == d.Height
+}
+
+func (d Dimensions) String() string {
+	return fmt.Sprintf("%dx%d", d.Width, d.Height)
+}
+
+// Transposed returns the dimensions with the smaller side as the width,
+// since that is the side the rectangle enumeration has to track.
+func (d Dimensions) Transposed() Dimensions {
+	if d.Height < d.Width {
+		return Dimensions{d.Height, d.Width}
+	}
+	return d
+}
+
+// Stats describe a count, beyond the number of grids.
+type Stats struct {
+	// The number of classes at the last height, or with Profile, the
+	// number of states.  Not set by CountExhaustive.
+	NumClasses int
+
+	// Only from CountExhaustive, the number of grids which were not
+	// counted
+	NotValid *big.Int
+
+	// Only with Rules.CountRegions, the count by number of regions
+	ByRegions []*big.Int
+
+	// Only with Weights, the count by each statistic
+	Distribution Polynomial
+
+	Elapsed time.Duration
+}
+
+// CountRectangle counts the width x height grids with the rectangle
+// enumeration.  The context is checked after every height.
+func CountRectangle(ctx context.Context, width int, height int, opts Options) (*big.Int, Stats, error) {
+	if width < 1 || height < 1 {
+		return nil, Stats{}, fmt.Errorf("bad dimensions %v", Dimensions{width, height})
+	}
+	if err := opts.checkRectangle(); err != nil {
+		return nil, Stats{}, err
+	}
+	start := time.Now()
+	t := opts.transfer()
+	d := transpose(t, Dimensions{width, height})
+	if opts.Profile && d.Width > equiv.MaxPackedCells {
+		return nil, Stats{}, fmt.Errorf("Profile only supports widths up to %d", equiv.MaxPackedCells)
+	}
+
+	var resumed *checkpoint
+	if opts.Resume != "" {
+		c, err := loadCheckpoint(opts.Resume)
+		if err != nil {
+			return nil, Stats{}, fmt.Errorf("couldn't resume from %v: %v", opts.Resume, err)
+		}
+		if c.Width != d.Width {
+			return nil, Stats{}, fmt.Errorf("%v is for width %d, not %d", opts.Resume, c.Width, d.Width)
+		}
+		resumed = c
+	}
+
+	var result HeightResult
+	err := countWidth(ctx, t, d.Width, d.Height, resumed, opts, func(r HeightResult) {
+		if r.Height == d.Height {
+			result = r
+		}
+	})
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	return result.Count, Stats{
+		NumClasses:   result.NumClasses,
+		ByRegions:    result.ByRegions,
+		Distribution: result.Distribution,
+		Elapsed:      time.Since(start),
+	}, nil
+}
+
+// CountSquare counts the n x n grids by expanding squares, which only
+// supports the grids of A166755, from n = 2.  The context is checked
+// after every size.
+func CountSquare(ctx context.Context, n int, opts Options) (*big.Int, Stats, error) {
+	if n < 2 {
+		// The classes of a single cell do not say it is one region.
+		return nil, Stats{}, fmt.Errorf("bad size %d", n)
+	}
+	if err := opts.checkTwoColors("CountSquare"); err != nil {
+		return nil, Stats{}, err
+	}
+	start := time.Now()
+	ec := InitEquivalenceClasses()
+	for ec.Size < n {
+		if err := ctx.Err(); err != nil {
+			return nil, Stats{}, err
+		}
+		ec = nextEquivalenceClasses(ec, nil, opts)
+	}
+	return new(big.Int).SetUint64(ec.CountValid), Stats{
+		NumClasses: len(ec.Classes),
+		Elapsed:    time.Since(start),
+	}, nil
+}
+
+// CountExhaustive counts the width x height grids by checking every
+// one of them.  Once the context is done, the rest are skipped.
+func CountExhaustive(ctx context.Context, width int, height int, opts Options) (*big.Int, Stats, error) {
+	if width < 1 || height < 1 {
+		return nil, Stats{}, fmt.Errorf("bad dimensions %v", Dimensions{width, height})
+	}
+	if err := opts.checkRules(); err != nil {
+		return nil, Stats{}, err
+	}
+	if opts.Symmetric {
+		if err := opts.checkTwoColors("Symmetric"); err != nil {
+			return nil, Stats{}, err
+		}
+		if err := checkSquares("Symmetric", []Dimensions{{width, height}}); err != nil {
+			return nil, Stats{}, err
+		}
+	}
+	start := time.Now()
+	total, err := exhaustiveCount(ctx, Dimensions{width, height}, opts)
+	if err != nil {
+		return nil, Stats{}, err
+	}
+	stats := Stats{
+		NotValid: big.NewInt(int64(total.NotValid)),
+		Elapsed:  time.Since(start),
+	}
+	for _, count := range total.ByRegions {
+		stats.ByRegions = append(stats.ByRegions, big.NewInt(int64(count)))
+	}
+	if opts.Weights.Any() {
+		p := Constant(0)
+		for km, count := range total.Distribution {
+			p = p.Plus(Monomial(km[0], km[1]).Times(Constant(int64(count))))
+		}
+		stats.Distribution = p
+	}
+	return big.NewInt(int64(total.Valid)), stats, nil
+}
@@ -0,0 +1,191 @@
+package enumerate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// A166755, from n = 1
+var a166755 = []string{"0", "12", "106", "1254", "32426"}
+
+func TestCount_A166755(t *testing.T) {
+	ctx := context.Background()
+	profile := Options{Profile: true}
+	for i, expected := range a166755 {
+		n := i + 1
+		count, _, err := CountRectangle(ctx, n, n, Options{})
+		if err != nil || count.String() != expected {
+			t.Errorf("CountRectangle(%d) = %v, %v, expected %v", n, count, err, expected)
+		}
+		count, _, err = CountRectangle(ctx, n, n, profile)
+		if err != nil || count.String() != expected {
+			t.Errorf("CountRectangle(%d) with Profile = %v, %v, expected %v", n, count, err, expected)
+		}
+		if n <= 4 {
+			count, _, err = CountExhaustive(ctx, n, n, Options{})
+			if err != nil || count.String() != expected {
+				t.Errorf("CountExhaustive(%d) = %v, %v, expected %v", n, count, err, expected)
+			}
+		}
+		if n == 1 {
+			continue
+		}
+		count, _, err = CountSquare(ctx, n, Options{})
+		if err != nil || count.String() != expected {
+			t.Errorf("CountSquare(%d) = %v, %v, expected %v", n, count, err, expected)
+		}
+	}
+}
+
+func TestCountRectangle_MatchesExhaustive(t *testing.T) {
+	ctx := context.Background()
+	three := &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square}
+	unrestricted := []equiv.Constraint{equiv.Unrestricted, equiv.Unrestricted}
+	regions := &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Square, Constraints: unrestricted, CountRegions: true}
+	cases := []struct {
+		Name string
+		Opts Options
+
+		// If nil, small rectangles of each shape
+		Dims []Dimensions
+	}{
+		{"two colors", Options{}, nil},
+		{"three colors", Options{Rules: three}, nil},
+		{"torus", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Torus, Lattice: equiv.Square}}, nil},
+		{"cells", Options{Weights: Weights{Cells: true, Interface: true}}, nil},
+		{"modular", Options{Modular: true}, nil},
+		{"regions", Options{Rules: regions}, nil},
+		{"max regions", Options{Rules: regions, MaxRegions: 3}, nil},
+		{"cylinder", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Cylinder, Lattice: equiv.Square}}, nil},
+		{"cylinder, three colors", Options{Rules: &equiv.Rules{NumColors: 3, Boundary: equiv.Cylinder, Lattice: equiv.Square}}, nil},
+		{"8-connected", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Square, Adjacency: []equiv.Adjacency{equiv.Eight, equiv.Eight}}}, nil},
+		{"mixed adjacency", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Square, Adjacency: []equiv.Adjacency{equiv.Four, equiv.Eight}}}, nil},
+		{"mixed adjacency, three colors", Options{Rules: &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square, Adjacency: []equiv.Adjacency{equiv.Eight, equiv.Four, equiv.Eight}}}, nil},
+		{"hexagonal", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Hexagonal}}, nil},
+		{"hexagonal regions", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Hexagonal, Constraints: unrestricted, CountRegions: true}}, nil},
+		// A row of n rhombi has 2n triangles.
+		{"triangular", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Triangular}}, []Dimensions{{2, 3}, {3, 2}, {3, 3}}},
+		{"triangular regions", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Triangular, Constraints: unrestricted, CountRegions: true}}, []Dimensions{{2, 3}, {3, 2}, {3, 3}}},
+		// Each row is a w x w layer.
+		{"cubic", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Cubic}}, []Dimensions{{2, 2}, {2, 3}, {2, 4}, {3, 2}}},
+		{"cubic regions", Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Cubic, Constraints: unrestricted, CountRegions: true}}, []Dimensions{{2, 2}, {2, 3}, {2, 4}}},
+	}
+	for _, c := range cases {
+		dims := c.Dims
+		if dims == nil {
+			dims = []Dimensions{{2, 3}, {3, 2}, {3, 3}, {4, 3}}
+		}
+		for _, d := range dims {
+			count, stats, err := CountRectangle(ctx, d.Width, d.Height, c.Opts)
+			if err != nil {
+				t.Fatalf("%v %v: %v", c.Name, d, err)
+			}
+			expected, expectedStats, err := CountExhaustive(ctx, d.Width, d.Height, c.Opts)
+			if err != nil {
+				t.Fatalf("%v %v: %v", c.Name, d, err)
+			}
+			if count.Cmp(expected) != 0 {
+				t.Errorf("%v %v: rectangle %v, exhaustive %v", c.Name, d, count, expected)
+			}
+			if c.Opts.Weights.Any() && stats.Distribution.String() != expectedStats.Distribution.String() {
+				t.Errorf("%v %v: rectangle %v, exhaustive %v", c.Name, d, stats.Distribution, expectedStats.Distribution)
+			}
+			if fmt.Sprint(stats.ByRegions) != fmt.Sprint(expectedStats.ByRegions) {
+				t.Errorf("%v %v: rectangle %v, exhaustive %v by regions", c.Name, d, stats.ByRegions, expectedStats.ByRegions)
+			}
+		}
+	}
+}
+
+func TestCount_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := CountRectangle(ctx, 4, 4, Options{}); err != context.Canceled {
+		t.Errorf("CountRectangle returned %v", err)
+	}
+	if _, _, err := CountSquare(ctx, 4, Options{}); err != context.Canceled {
+		t.Errorf("CountSquare returned %v", err)
+	}
+	// 2^36 grids, which would never finish if they were all generated
+	if _, _, err := CountExhaustive(ctx, 6, 6, Options{}); err != context.Canceled {
+		t.Errorf("CountExhaustive returned %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, err := CountExhaustive(ctx, 6, 6, Options{}); err != context.DeadlineExceeded {
+		t.Errorf("CountExhaustive returned %v after its deadline", err)
+	}
+}
+
+func TestCount_Unsupported(t *testing.T) {
+	ctx := context.Background()
+	three := &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square}
+	if _, _, err := CountSquare(ctx, 3, Options{Rules: three}); err == nil {
+		t.Errorf("expected an error from CountSquare with three colors")
+	}
+	if _, _, err := CountRectangle(ctx, 3, 3, Options{Rules: three, Profile: true}); err == nil {
+		t.Errorf("expected an error from CountRectangle with three colors and Profile")
+	}
+	if _, _, err := CountRectangle(ctx, 3, 3, Options{Weights: Weights{Cells: true}, Modular: true}); err == nil {
+		t.Errorf("expected an error from CountRectangle with Weights and Modular")
+	}
+}
+
+// countingReporter counts the results reported.
+type countingReporter struct {
+	n int
+}
+
+func (c *countingReporter) Progress(r Result) { c.n += 1 }
+func (c *countingReporter) Report(r Result)   { c.n += 1 }
+func (c *countingReporter) Close() error      { return nil }
+
+func TestEnumeration_Unsupported(t *testing.T) {
+	var out bytes.Buffer
+	reported := &countingReporter{}
+	three := &equiv.Rules{NumColors: 3, Boundary: equiv.Plain, Lattice: equiv.Square}
+	cases := []Dimensions{{3, 3}}
+	errs := map[string]error{
+		"ExhaustiveEnumeration with Modular":       ExhaustiveEnumeration(cases, Options{Modular: true}, reported),
+		"ExhaustiveEnumeration with Symmetric 3x4": ExhaustiveEnumeration([]Dimensions{{3, 4}}, Options{Symmetric: true}, reported),
+		"SymmetricEnumeration with three colors":   SymmetricEnumeration(cases, Options{Rules: three}, reported),
+		"EquivalenceClassEnumeration with Resume":  EquivalenceClassEnumeration(cases, Options{Resume: "checkpoint"}, reported),
+		"RectangleEnumeration with Symmetric":      RectangleEnumeration(cases, Options{Symmetric: true}, reported),
+		"SampleEnumeration with three colors":      SampleEnumeration(&out, cases, 1, 1, Options{Rules: three}),
+		"UnrankEnumeration with Profile":           UnrankEnumeration(&out, cases, nil, Options{Profile: true}),
+		"cubic torus":                              RectangleEnumeration(cases, Options{Rules: &equiv.Rules{NumColors: 2, Boundary: equiv.Torus, Lattice: equiv.Cubic}}, reported),
+		"Cells with one color":                     RectangleEnumeration(cases, Options{Rules: &equiv.Rules{NumColors: 1, Boundary: equiv.Plain, Lattice: equiv.Square}, Weights: Weights{Cells: true}}, reported),
+	}
+	_, err := VerifyEnumeration(&out, 1, Options{})
+	errs["VerifyEnumeration up to 1"] = err
+	_, err = AuditEnumeration(&out, 2, Options{Rules: three})
+	errs["AuditEnumeration with three colors"] = err
+	for name, err := range errs {
+		if err == nil {
+			t.Errorf("expected an error from %v", name)
+		}
+	}
+	if out.Len() != 0 || reported.n != 0 {
+		t.Errorf("expected nothing counted, got %d results and:\n%v", reported.n, out.String())
+	}
+}
+
+func TestCountRectangle_Verbose(t *testing.T) {
+	var buf bytes.Buffer
+	opts := Options{Verbose: true, Progress: &buf}
+	if _, _, err := CountRectangle(context.Background(), 3, 3, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Initial row:", "Expanding ", "Counts at height 3:", " Height=3 "} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in the progress:\n%v", expected, buf.String())
+		}
+	}
+}
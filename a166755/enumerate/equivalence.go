@@ -0,0 +1,233 @@
+package enumerate
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+type EquivalenceClasses struct {
+	Size                 int
+	Classes              map[ClassKey]*equiv.GridBoundary
+	CountByClass         map[ClassKey]uint64
+	CountByPartitionSize map[int]uint64
+	CountValid           uint64
+}
+
+func NewEquivalenceClasses(size int) *EquivalenceClasses {
+	return &EquivalenceClasses{
+		Size:                 size,
+		Classes:              make(map[ClassKey]*equiv.GridBoundary),
+		CountByClass:         make(map[ClassKey]uint64),
+		CountByPartitionSize: make(map[int]uint64),
+		CountValid:           0,
+	}
+}
+
+func InitEquivalenceClasses() *EquivalenceClasses {
+	gb := equiv.GridBoundary{
+		Size:       1,
+		SolidColor: true,
+		White: equiv.EdgePartition{[][]int{
+			[]int{0},
+		}},
+		Black: equiv.EdgePartition{[][]int{}},
+	}
+	return &EquivalenceClasses{
+		Size: 1,
+		Classes: map[ClassKey]*equiv.GridBoundary{
+			keyOf(&gb): &gb,
+		},
+		CountByClass: map[ClassKey]uint64{
+			keyOf(&gb): 2,
+		},
+		CountByPartitionSize: map[int]uint64{
+			1: 2,
+		},
+		CountValid: 2,
+	}
+}
+
+type EquivalentGrids struct {
+	Boundary *equiv.GridBoundary
+	Count    uint64
+}
+
+func (e *EquivalenceClasses) AddGrids(g EquivalentGrids) {
+	classKey := keyOf(g.Boundary)
+	// fmt.Printf("Key %v count %v\n", classKey, g.Count)
+	// fmt.Printf("%v\n", g.Boundary.Plot())
+	e.Classes[classKey] = g.Boundary
+	e.CountByClass[classKey] += g.Count
+
+	partKey := len(g.Boundary.White.Sets) + len(g.Boundary.Black.Sets)
+	e.CountByPartitionSize[partKey] += g.Count
+	if partKey == 2 || (partKey == 1 && !g.Boundary.SolidColor) {
+		e.CountValid += g.Count
+	}
+}
+
+// EnumerateChildren sends the classes which follow gb, using only the
+// borders allowed (or all of them, if allowed is nil.)
+func EnumerateChildren(gb *equiv.GridBoundary, count uint64, allowed func(border []int) bool, out chan<- EquivalentGrids) {
+	whiteExpansion := gb.White.Expand()
+	blackExpansion := gb.Black.Expand()
+
+	// For a 3x3 grid we expand to 4x4 which has
+	// -3 -2 -1 0 1 2 3
+	config := combinations.IndicatorConfig{
+		Size:   2*gb.Size + 1,
+		Offset: gb.Size,
+	}
+
+	send := func(border []int) {
+		if allowed != nil && !allowed(border) {
+			return
+		}
+		out <- EquivalentGrids{
+			gb.Expand(border),
+			count,
+		}
+	}
+
+	// If the existing border is a single color, but the grid is
+	// not monochromatic, then there is only one way to expand it.
+	if len(gb.Black.Sets) == 0 && !gb.SolidColor {
+		allZeros := make([]int, 2*gb.Size+1)
+		send(allZeros)
+		return
+	}
+
+	// If there is a single group of one color, we can create a solid
+	// border of the other color.
+	if len(gb.White.Sets) == 1 {
+		allOnes := make([]int, 2*gb.Size+1)
+		for i := range allOnes {
+			allOnes[i] = 1
+		}
+		send(allOnes)
+	}
+
+	if len(gb.Black.Sets) == 1 {
+		allZeros := make([]int, 2*gb.Size+1)
+		send(allZeros)
+	}
+
+	// Otherwise, the new border should preserve any existing
+	// single-colored region, to avoid leaving it disconnected.
+	// At least one square should be extended to the new border,
+	// for each set.
+	gens := make([]combinations.SetGenerator, 0,
+		len(whiteExpansion.Sets)+len(blackExpansion.Sets)+1)
+
+	for _, s := range whiteExpansion.Sets {
+		gens = append(gens, &combinations.MandatoryZero{config, s})
+	}
+	gens = append(gens, &combinations.FreeChoice{config, 0})
+	for _, s := range blackExpansion.Sets {
+		gens = append(gens, &combinations.MandatoryOne{config, s})
+	}
+
+	ch := make(chan combinations.IndicatorMap)
+	go combinations.Product(gens, ch)
+
+	for boundary := range ch {
+		send(boundary.Values)
+	}
+}
+
+func equivalenceClassEnumerator(allowed func(border []int) bool, workQueue <-chan EquivalentGrids, results chan<- EquivalentGrids) {
+	for g := range workQueue {
+		EnumerateChildren(g.Boundary, g.Count, allowed, results)
+	}
+}
+
+func equivalenceClassAccumulator(size int, workQueue <-chan EquivalentGrids, results chan<- *EquivalenceClasses) {
+	ec := NewEquivalenceClasses(size)
+	for child := range workQueue {
+		ec.AddGrids(child)
+	}
+	results <- ec
+}
+
+// nextEquivalenceClasses expands every class by one row and column,
+// using only the borders allowed (or all of them, if allowed is nil.)
+func nextEquivalenceClasses(prevEc *EquivalenceClasses, allowed func(border []int) bool, opts Options) *EquivalenceClasses {
+	size := prevEc.Size + 1
+	prevClasses := make(chan EquivalentGrids, 100)
+	newClasses := make(chan EquivalentGrids, 100)
+	newResult := make(chan *EquivalenceClasses)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.numWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			equivalenceClassEnumerator(allowed, prevClasses, newClasses)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(newClasses)
+	}()
+
+	go func() {
+		for key, boundary := range prevEc.Classes {
+			count := prevEc.CountByClass[key]
+			prevClasses <- EquivalentGrids{boundary, count}
+		}
+		close(prevClasses)
+	}()
+
+	go func() {
+		equivalenceClassAccumulator(size, newClasses, newResult)
+		close(newResult)
+	}()
+
+	return <-newResult
+}
+
+// runEquivalenceClasses expands the squares up to size max, calling
+// report with each.
+func runEquivalenceClasses(max int, opts Options, report func(ec *EquivalenceClasses)) {
+	ec := InitEquivalenceClasses()
+	for ec.Size < max {
+		ec = nextEquivalenceClasses(ec, nil, opts)
+		report(ec)
+	}
+}
+
+// EquivalenceClassEnumeration reports the count of every square up to
+// the largest case, by expanding squares.
+func EquivalenceClassEnumeration(cases []Dimensions, opts Options, out Reporter) error {
+	if err := opts.checkTwoColorMethod("EquivalenceClassEnumeration"); err != nil {
+		return err
+	}
+	if err := checkSquares("EquivalenceClassEnumeration", cases); err != nil {
+		return err
+	}
+	// This is a bit silly, we have to generate all smaller cases anyway.
+	max := 0
+	for _, d := range cases {
+		if d.Width > max {
+			max = d.Width
+		}
+	}
+	start := time.Now()
+	runEquivalenceClasses(max, opts, func(ec *EquivalenceClasses) {
+		out.Report(Result{
+			HeightResult: HeightResult{
+				Width:      ec.Size,
+				Height:     ec.Size,
+				Count:      new(big.Int).SetUint64(ec.CountValid),
+				NumClasses: len(ec.Classes),
+			},
+			Method:  "square",
+			Elapsed: time.Since(start),
+		})
+	})
+	return nil
+}
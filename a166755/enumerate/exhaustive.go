@@ -0,0 +1,285 @@
+package enumerate
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// hasOneRegionPerColor checks that every Connected color is used, and
+// forms a single region, and that no Absent color is used.  With two
+// Connected colors, this means the grid has exactly two regions.
+func hasOneRegionPerColor(board equiv.Board, rules *equiv.Rules, grid combinations.IndicatorMap) bool {
+	if len(grid.Values) != board.Width*board.Height {
+		panic("grid is wrong size")
+	}
+	colors := grid.Values
+	visited := make([]bool, len(colors))
+
+	numComponents := make([]int, rules.NumColors)
+	for y := 1; y <= board.Height; y++ {
+		for x := 1; x <= board.Width; x++ {
+			component := board.ConnectedComponent(colors, equiv.Coord{x, y}, visited)
+			if len(component) > 0 {
+				c := colors[board.Index(component[0])]
+				numComponents[c] += 1
+				switch rules.ConstraintOf(c) {
+				case equiv.Connected:
+					if numComponents[c] > 1 {
+						return false
+					}
+				case equiv.Absent:
+					return false
+				}
+			}
+		}
+	}
+	for c, n := range numComponents {
+		if rules.ConstraintOf(c) == equiv.Connected && n == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// numRegions counts the regions of every color.
+func numRegions(board equiv.Board, grid combinations.IndicatorMap) int {
+	colors := grid.Values
+	visited := make([]bool, len(colors))
+
+	numComponents := 0
+	for y := 1; y <= board.Height; y++ {
+		for x := 1; x <= board.Width; x++ {
+			component := board.ConnectedComponent(colors, equiv.Coord{x, y}, visited)
+			if len(component) > 0 {
+				numComponents += 1
+			}
+		}
+	}
+	return numComponents
+}
+
+type Count struct {
+	Valid    int
+	NotValid int
+
+	// Only with -regions
+	ByRegions []int
+
+	// Only with -cells or -interface, indexed by the number of black
+	// cells and the length of the interface
+	Distribution map[[2]int]int
+
+	// Only with -symmetric, the valid grids fixed by each
+	// element of Symmetries
+	Fixed []int
+}
+
+func (c *Count) AddRegions(n int) {
+	for len(c.ByRegions) <= n {
+		c.ByRegions = append(c.ByRegions, 0)
+	}
+	c.ByRegions[n] += 1
+}
+
+// AddWeights counts one grid with the statistics in weights.
+func (c *Count) AddWeights(board equiv.Board, weights Weights, grid combinations.IndicatorMap) {
+	if !weights.Any() {
+		return
+	}
+	if c.Distribution == nil {
+		c.Distribution = make(map[[2]int]int)
+	}
+	k := 0
+	if weights.Cells {
+		for _, color := range grid.Values {
+			if color == 1 {
+				k += 1
+			}
+		}
+	}
+	m := 0
+	if weights.Interface {
+		m = board.InterfaceLength(grid.Values)
+	}
+	c.Distribution[[2]int{k, m}] += 1
+}
+
+// exhaustiveWorker counts the grids from inputs, skipping them once
+// done is closed.
+func exhaustiveWorker(board equiv.Board, rules *equiv.Rules, opts Options, done <-chan struct{}, inputs <-chan combinations.IndicatorMap, result chan<- Count) {
+	weights := opts.Weights
+	var total Count
+	for grid := range inputs {
+		select {
+		case <-done:
+			continue
+		default:
+		}
+		if rules.CountRegions {
+			n := numRegions(board, grid)
+			if opts.MaxRegions == 0 || n <= opts.MaxRegions {
+				total.Valid += 1
+				total.AddRegions(n)
+				total.AddWeights(board, weights, grid)
+			} else {
+				total.NotValid += 1
+			}
+		} else if hasOneRegionPerColor(board, rules, grid) {
+			total.Valid += 1
+			total.AddWeights(board, weights, grid)
+			if opts.Symmetric {
+				countFixed(board.Width, grid, &total)
+			}
+		} else {
+			total.NotValid += 1
+		}
+	}
+	result <- total
+}
+
+// exhaustiveCount checks every grid, returning the context's error if
+// it is done before they have all been counted.
+func exhaustiveCount(ctx context.Context, d Dimensions, opts Options) (Count, error) {
+	rules := opts.rules()
+	board := equiv.NewBoard(d.Width, d.Height, rules)
+	numColors := rules.NumColors
+	numCells := board.Width * board.Height
+	cells := make([]combinations.SetGenerator, numCells)
+	config := combinations.IndicatorConfig{numCells, 0}
+	choices := make([]int, numColors)
+	for c := range choices {
+		choices[c] = c
+	}
+	for i := 0; i < numCells; i++ {
+		if numColors == 2 {
+			cells[i] = &combinations.FreeChoice{config, i}
+		} else {
+			cells[i] = &combinations.FreeValue{C: config, Index: i, Choices: choices}
+		}
+	}
+
+	numWorkers := opts.numWorkers()
+	allGrids := make(chan combinations.IndicatorMap, numWorkers*2)
+
+	// Divide up the grid by cell
+	numGenerators := numColors
+	prefixLength := 1
+	for numGenerators*numColors <= numWorkers && prefixLength < numCells {
+		numGenerators *= numColors
+		prefixLength += 1
+	}
+	var gg sync.WaitGroup
+	prefixes := combinations.ProductList(cells[:prefixLength])
+	if len(prefixes) != numGenerators {
+		panic("prefixes are not a power of the number of colors.")
+	}
+	for i := 0; i < numGenerators; i++ {
+		gg.Add(1)
+		go func(me int) {
+			defer gg.Done()
+			combinations.ProductWithPrefixUntil(prefixes[me], cells[prefixLength:], allGrids, ctx.Done())
+		}(i)
+	}
+
+	go func() {
+		gg.Wait()
+		close(allGrids)
+	}()
+
+	var wg sync.WaitGroup
+	results := make(chan Count, numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exhaustiveWorker(board, rules, opts, ctx.Done(), allGrids, results)
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var total Count
+	for c := range results {
+		//fmt.Printf("Worker: %v\n", c)
+		total.Valid += c.Valid
+		total.NotValid += c.NotValid
+		for n, count := range c.ByRegions {
+			for len(total.ByRegions) <= n {
+				total.ByRegions = append(total.ByRegions, 0)
+			}
+			total.ByRegions[n] += count
+		}
+		for km, count := range c.Distribution {
+			if total.Distribution == nil {
+				total.Distribution = make(map[[2]int]int)
+			}
+			total.Distribution[km] += count
+		}
+		for i, count := range c.Fixed {
+			if total.Fixed == nil {
+				total.Fixed = make([]int, len(c.Fixed))
+			}
+			total.Fixed[i] += count
+		}
+	}
+	return total, ctx.Err()
+}
+
+// ExhaustiveEnumeration reports the count of each case, or with
+// opts.Symmetric, the number fixed by each symmetry.
+func ExhaustiveEnumeration(cases []Dimensions, opts Options, out Reporter) error {
+	if err := opts.checkOther("ExhaustiveEnumeration", true); err != nil {
+		return err
+	}
+	if opts.Symmetric {
+		if err := checkSquares("Symmetric", cases); err != nil {
+			return err
+		}
+	}
+	for _, d := range cases {
+		if opts.Symmetric {
+			start := time.Now()
+			total, err := exhaustiveCount(context.Background(), d, opts)
+			if err != nil {
+				return err
+			}
+			fixed := make([]*big.Int, len(Symmetries))
+			for i := range fixed {
+				fixed[i] = big.NewInt(0)
+				if total.Fixed != nil {
+					fixed[i].SetInt64(int64(total.Fixed[i]))
+				}
+			}
+			r := symmetricResult(d.Width, fixed, "exhaustive")
+			r.Elapsed = time.Since(start)
+			out.Report(r)
+			continue
+		}
+		count, stats, err := CountExhaustive(context.Background(), d.Width, d.Height, opts)
+		if err != nil {
+			return err
+		}
+		out.Report(Result{
+			HeightResult: HeightResult{
+				Width:        d.Width,
+				Height:       d.Height,
+				Count:        count,
+				ByRegions:    stats.ByRegions,
+				Distribution: stats.Distribution,
+			},
+			Method:   "exhaustive",
+			NotValid: stats.NotValid,
+			Weights:  opts.Weights,
+			Elapsed:  stats.Elapsed,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package enumerate
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// At large widths, the counts of each class and the lists of successors
+// no longer fit in memory.  A diskStore keeps them in files instead,
+// each a run of records sorted by key, and aggregates them with an
+// external merge: the counts are joined with the successors of each
+// class, and the products are combined in memory until the budget is
+// reached, then written out as another run.  Merging those runs gives
+// the counts at the next height.
+//
+// Only the keys of the classes seen so far, and of the valid classes,
+// stay in memory, along with the classes whose successors have not yet
+// been found.  The counts may not use Weights.
+
+// diskRecord is either a count, of Key, or a successor, from Key to
+// Next.
+type diskRecord struct {
+	Key   ClassKey
+	Next  ClassKey
+	Count *big.Int
+}
+
+// A rough estimate of the memory used by each record, beyond its key.
+const diskRecordOverhead = 128
+
+func (rec diskRecord) size() int {
+	return len(rec.Key.Long) + len(rec.Next.Long) + diskRecordOverhead
+}
+
+type runWriter struct {
+	f   *os.File
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func createRun(filename string) (*runWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &runWriter{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (rw *runWriter) bytes(b []byte) {
+	if rw.err == nil {
+		_, rw.err = rw.w.Write(b)
+	}
+}
+
+func (rw *runWriter) uvarint(n uint64) {
+	m := binary.PutUvarint(rw.buf[:], n)
+	rw.bytes(rw.buf[:m])
+}
+
+func (rw *runWriter) key(k ClassKey) {
+	rw.bytes(k.Packed[:])
+	rw.uvarint(uint64(len(k.Long)))
+	rw.bytes([]byte(k.Long))
+}
+
+// Write appends a record; successor records include Next.
+func (rw *runWriter) Write(rec diskRecord, successor bool) {
+	rw.key(rec.Key)
+	if successor {
+		rw.key(rec.Next)
+	}
+	b := rec.Count.Bytes()
+	rw.uvarint(uint64(len(b)))
+	rw.bytes(b)
+}
+
+func (rw *runWriter) Close() error {
+	if rw.err == nil {
+		rw.err = rw.w.Flush()
+	}
+	if err := rw.f.Close(); rw.err == nil {
+		rw.err = err
+	}
+	return rw.err
+}
+
+type runReader struct {
+	f         *os.File
+	r         *bufio.Reader
+	successor bool
+
+	// The current record, after Next returns true
+	Record diskRecord
+	err    error
+}
+
+func openRun(filename string, successor bool) (*runReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &runReader{f: f, r: bufio.NewReader(f), successor: successor}, nil
+}
+
+func (rr *runReader) key(k *ClassKey) {
+	if rr.err != nil {
+		return
+	}
+	if _, rr.err = io.ReadFull(rr.r, k.Packed[:]); rr.err != nil {
+		return
+	}
+	n, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		rr.err = err
+		return
+	}
+	long := make([]byte, n)
+	_, rr.err = io.ReadFull(rr.r, long)
+	k.Long = string(long)
+}
+
+// Next reads the next record, returning false at the end of the run
+// or on an error.
+func (rr *runReader) Next() bool {
+	if rr.err != nil {
+		return false
+	}
+	rr.Record = diskRecord{}
+	if _, err := rr.r.Peek(1); err == io.EOF {
+		return false
+	}
+	rr.key(&rr.Record.Key)
+	if rr.successor {
+		rr.key(&rr.Record.Next)
+	}
+	n, err := binary.ReadUvarint(rr.r)
+	if rr.err == nil && err != nil {
+		rr.err = err
+	}
+	if rr.err != nil {
+		return false
+	}
+	b := make([]byte, n)
+	if _, rr.err = io.ReadFull(rr.r, b); rr.err != nil {
+		return false
+	}
+	rr.Record.Count = new(big.Int).SetBytes(b)
+	return true
+}
+
+func (rr *runReader) Close() error {
+	if err := rr.f.Close(); rr.err == nil {
+		rr.err = err
+	}
+	if rr.err == io.EOF || rr.err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%v is truncated", rr.f.Name())
+	}
+	return rr.err
+}
+
+// mergedRuns reads several runs as one, in order of key.  The runs
+// which have not ended are kept in a heap.
+type mergedRuns struct {
+	runs []*runReader
+	live []*runReader
+}
+
+func (m *mergedRuns) Len() int { return len(m.live) }
+func (m *mergedRuns) Less(i, j int) bool {
+	return m.live[i].Record.Key.Less(m.live[j].Record.Key)
+}
+func (m *mergedRuns) Swap(i, j int) { m.live[i], m.live[j] = m.live[j], m.live[i] }
+func (m *mergedRuns) Push(x interface{}) {
+	m.live = append(m.live, x.(*runReader))
+}
+func (m *mergedRuns) Pop() interface{} {
+	rr := m.live[len(m.live)-1]
+	m.live = m.live[:len(m.live)-1]
+	return rr
+}
+
+func openMerged(filenames []string, successor bool) (*mergedRuns, error) {
+	m := &mergedRuns{}
+	for _, filename := range filenames {
+		rr, err := openRun(filename, successor)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.runs = append(m.runs, rr)
+		if rr.Next() {
+			m.live = append(m.live, rr)
+		}
+	}
+	heap.Init(m)
+	return m, nil
+}
+
+// Peek returns the run with the least key, or nil at the end.
+func (m *mergedRuns) Peek() *runReader {
+	if len(m.live) == 0 {
+		return nil
+	}
+	return m.live[0]
+}
+
+// Advance moves past the record returned by Peek.
+func (m *mergedRuns) Advance() {
+	if m.live[0].Next() {
+		heap.Fix(m, 0)
+	} else {
+		heap.Pop(m)
+	}
+}
+
+func (m *mergedRuns) Close() error {
+	var ret error
+	for _, rr := range m.runs {
+		if err := rr.Close(); ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+type diskStore struct {
+	Dir string
+
+	// The approximate number of bytes of records to hold in memory
+	Budget int
+
+	// Successors not yet written to a run
+	mu         sync.Mutex
+	successors []diskRecord
+	size       int
+
+	successorRuns []string
+
+	// The run holding the counts at the current height
+	counts    string
+	NumCounts int
+
+	numFiles int
+}
+
+// newDiskStore keeps its files in a new directory within dir.
+func newDiskStore(dir string, budget int) (*diskStore, error) {
+	tmp, err := ioutil.TempDir(dir, "a166755-")
+	if err != nil {
+		return nil, err
+	}
+	return &diskStore{Dir: tmp, Budget: budget}, nil
+}
+
+// Remove deletes all the files.
+func (d *diskStore) Remove() error {
+	return os.RemoveAll(d.Dir)
+}
+
+func (d *diskStore) newFilename() string {
+	d.numFiles += 1
+	return filepath.Join(d.Dir, fmt.Sprintf("run%d", d.numFiles))
+}
+
+// writeRun sorts the records and writes them to a new file.
+func (d *diskStore) writeRun(records []diskRecord, successor bool) (string, error) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key.Less(records[j].Key)
+	})
+	filename := d.newFilename()
+	rw, err := createRun(filename)
+	if err != nil {
+		return "", err
+	}
+	for _, rec := range records {
+		rw.Write(rec, successor)
+	}
+	return filename, rw.Close()
+}
+
+// SetCounts replaces the counts at the current height.
+func (d *diskStore) SetCounts(counts map[ClassKey]EdgeClass) error {
+	records := make([]diskRecord, 0, len(counts))
+	for k, v := range counts {
+		records = append(records, diskRecord{Key: k, Count: v.Count})
+	}
+	filename, err := d.writeRun(records, false)
+	if err != nil {
+		return err
+	}
+	if d.counts != "" {
+		os.Remove(d.counts)
+	}
+	d.counts = filename
+	d.NumCounts = len(records)
+	return nil
+}
+
+// AddSuccessors records the successors of a class; it is safe to call
+// from several workers at once.
+func (d *diskStore) AddSuccessors(key ClassKey, successors []EdgeClass) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range successors {
+		rec := diskRecord{Key: key, Next: e.Key, Count: e.Count}
+		d.successors = append(d.successors, rec)
+		d.size += rec.size()
+	}
+	if d.size > d.Budget {
+		return d.flushSuccessors()
+	}
+	return nil
+}
+
+func (d *diskStore) flushSuccessors() error {
+	if len(d.successors) == 0 {
+		return nil
+	}
+	filename, err := d.writeRun(d.successors, true)
+	if err != nil {
+		return err
+	}
+	d.successorRuns = append(d.successorRuns, filename)
+	d.successors = nil
+	d.size = 0
+	return nil
+}
+
+// Aggregate replaces the counts with those at the next height.
+func (d *diskStore) Aggregate() error {
+	if err := d.flushSuccessors(); err != nil {
+		return err
+	}
+
+	counts, err := openRun(d.counts, false)
+	if err != nil {
+		return err
+	}
+	defer counts.Close()
+	successors, err := openMerged(d.successorRuns, true)
+	if err != nil {
+		return err
+	}
+	defer successors.Close()
+
+	products := make(map[ClassKey]*big.Int)
+	size := 0
+	productRuns := make([]string, 0)
+	spill := func() error {
+		records := make([]diskRecord, 0, len(products))
+		for k, n := range products {
+			records = append(records, diskRecord{Key: k, Count: n})
+		}
+		filename, err := d.writeRun(records, false)
+		if err != nil {
+			return err
+		}
+		productRuns = append(productRuns, filename)
+		products = make(map[ClassKey]*big.Int)
+		size = 0
+		return nil
+	}
+
+	for counts.Next() {
+		c := counts.Record
+		for s := successors.Peek(); s != nil && s.Record.Key.Less(c.Key); s = successors.Peek() {
+			successors.Advance()
+		}
+		for s := successors.Peek(); s != nil && s.Record.Key == c.Key; s = successors.Peek() {
+			product := new(big.Int).Mul(c.Count, s.Record.Count)
+			if exist, ok := products[s.Record.Next]; ok {
+				exist.Add(exist, product)
+			} else {
+				products[s.Record.Next] = product
+				size += diskRecord{Key: s.Record.Next}.size()
+			}
+			successors.Advance()
+		}
+		if size > d.Budget {
+			if err := spill(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := counts.Close(); err != nil {
+		return err
+	}
+	if err := successors.Close(); err != nil {
+		return err
+	}
+	if err := spill(); err != nil {
+		return err
+	}
+
+	// Merge the runs of products, adding up those with the same key.
+	merged, err := openMerged(productRuns, false)
+	if err != nil {
+		return err
+	}
+	filename := d.newFilename()
+	rw, err := createRun(filename)
+	if err != nil {
+		merged.Close()
+		return err
+	}
+	numCounts := 0
+	var current diskRecord
+	for rr := merged.Peek(); rr != nil; rr = merged.Peek() {
+		if current.Count != nil && rr.Record.Key == current.Key {
+			current.Count.Add(current.Count, rr.Record.Count)
+		} else {
+			if current.Count != nil {
+				rw.Write(current, false)
+				numCounts += 1
+			}
+			current = rr.Record
+		}
+		merged.Advance()
+	}
+	if current.Count != nil {
+		rw.Write(current, false)
+		numCounts += 1
+	}
+	if err := merged.Close(); err != nil {
+		rw.Close()
+		return err
+	}
+	if err := rw.Close(); err != nil {
+		return err
+	}
+
+	for _, f := range productRuns {
+		os.Remove(f)
+	}
+	os.Remove(d.counts)
+	d.counts = filename
+	d.NumCounts = numCounts
+	return nil
+}
+
+// ValidCount totals the counts of the classes for which valid is true.
+func (d *diskStore) ValidCount(valid func(ClassKey) bool) (*big.Int, error) {
+	counts, err := openRun(d.counts, false)
+	if err != nil {
+		return nil, err
+	}
+	total := big.NewInt(0)
+	for counts.Next() {
+		if valid(counts.Record.Key) {
+			total.Add(total, counts.Record.Count)
+		}
+	}
+	return total, counts.Close()
+}
+
+// UseDisk moves the counts and successors of the map to files in a
+// new directory within dir, holding about budget bytes in memory.
+func (s *SuccessorMap) UseDisk(dir string, budget int) error {
+	d, err := newDiskStore(dir, budget)
+	if err != nil {
+		return err
+	}
+	if err := d.SetCounts(s.CountByClass); err != nil {
+		d.Remove()
+		return err
+	}
+	s.CountByClass = nil
+	s.Disk = d
+
+	// Only the new classes are needed to find their successors.
+	s.keepClasses = false
+	s.Classes.Range(func(k, v interface{}) bool {
+		s.Classes.Delete(k)
+		return true
+	})
+	return nil
+}
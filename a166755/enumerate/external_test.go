@@ -0,0 +1,54 @@
+package enumerate
+
+import (
+	"testing"
+)
+
+func TestDiskStore_Error(t *testing.T) {
+	s := NewSuccessorMap(twoColorTransfer{}, 4, Options{})
+	if err := s.UseDisk(t.TempDir(), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	s.Disk.Remove()
+	if err := s.Run(4, func(r HeightResult) {}); err == nil {
+		t.Errorf("expected an error once the files were removed")
+	}
+}
+
+func TestDiskStore_MatchesMemory(t *testing.T) {
+	// With a budget of one byte, every class's successors, and the
+	// products of every count, are written out as a separate run.
+	for _, width := range []int{3, 4, 5} {
+		memory := make([]HeightResult, 0)
+		NewSuccessorMap(twoColorTransfer{}, width, Options{}).Run(6, func(r HeightResult) {
+			memory = append(memory, r)
+		})
+
+		s := NewSuccessorMap(twoColorTransfer{}, width, Options{})
+		if err := s.UseDisk(t.TempDir(), 1); err != nil {
+			t.Fatal(err)
+		}
+		disk := make([]HeightResult, 0)
+		if err := s.Run(6, func(r HeightResult) { disk = append(disk, r) }); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Disk.successorRuns) < 2 {
+			t.Errorf("width %d: expected several runs of successors, got %d", width, len(s.Disk.successorRuns))
+		}
+		s.Classes.Range(func(k, v interface{}) bool {
+			t.Errorf("width %d: class %v kept in memory", width, k)
+			return false
+		})
+
+		if len(disk) != len(memory) {
+			t.Fatalf("width %d: %d heights on disk, %d in memory", width, len(disk), len(memory))
+		}
+		for i := range disk {
+			if disk[i].Count.Cmp(memory[i].Count) != 0 || disk[i].NumClasses != memory[i].NumClasses {
+				t.Errorf("%dx%d: disk %v with %d classes, memory %v with %d", width, disk[i].Height,
+					disk[i].Count, disk[i].NumClasses, memory[i].Count, memory[i].NumClasses)
+			}
+		}
+		s.Disk.Remove()
+	}
+}
@@ -0,0 +1,75 @@
+package enumerate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// Enumerate sends every grid with exactly two regions to out, in order
+// of rank, then closes it.  It walks the classes depth-first, skipping
+// any row after which the grid cannot be completed, so every row it
+// tries leads to at least one grid.
+func (r *Ranker) Enumerate(out chan<- [][]int) {
+	grid := make([][]int, r.Height)
+	var visit func(h int, key ClassKey, transform equiv.Transform)
+	visit = func(h int, key ClassKey, transform equiv.Transform) {
+		if h == r.Height {
+			ret := make([][]int, r.Height)
+			copy(ret, grid)
+			out <- ret
+			return
+		}
+		for _, ch := range r.choices(h, key, transform) {
+			if r.completion(h, ch.Key).Sign() == 0 {
+				continue
+			}
+			grid[h] = ch.Row
+			visit(h+1, ch.Key, ch.Transform)
+		}
+	}
+	if r.Total.Sign() > 0 {
+		visit(0, ClassKey{}, equiv.Transform{})
+	}
+	close(out)
+}
+
+// ListEnumeration writes every grid with exactly two regions to a
+// file, drawn as by showGrid and separated by blank lines.  The number
+// of grids of each size is also written to out.
+func ListEnumeration(out io.Writer, cases []Dimensions, filename string, opts Options) error {
+	if err := opts.checkTwoColorMethod("ListEnumeration"); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	for _, d := range cases {
+		r := NewRanker(d.Width, d.Height, opts)
+		var header string
+		if d.IsSquare() {
+			header = fmt.Sprintf("**** N=%v | grids=%v", d.Width, r.Total)
+		} else {
+			header = fmt.Sprintf("**** %v | grids=%v", d, r.Total)
+		}
+		fmt.Fprintf(out, "%v\n\n", header)
+		fmt.Fprintf(w, "%v\n\n", header)
+
+		ch := make(chan [][]int)
+		go r.Enumerate(ch)
+		for grid := range ch {
+			fmt.Fprintf(w, "%v\n", showGrid(grid))
+		}
+	}
+	err = w.Flush()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package enumerate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRanker_Enumerate(t *testing.T) {
+	for _, d := range []Dimensions{{1, 1}, {1, 2}, {2, 2}, {2, 3}, {3, 2}, {3, 3}, {4, 3}, {3, 4}, {4, 4}} {
+		valid, _ := twoRegionGrids(d.Width, d.Height)
+		r := NewRanker(d.Width, d.Height, Options{})
+		ch := make(chan [][]int)
+		go r.Enumerate(ch)
+
+		i := 0
+		for grid := range ch {
+			if i < len(valid) && !reflect.DeepEqual(grid, valid[i]) {
+				t.Errorf("%v: grid %d is %v, expected %v", d, i, grid, valid[i])
+			}
+			i += 1
+		}
+		if i != len(valid) {
+			t.Errorf("%v: %d grids listed, expected %d", d, i, len(valid))
+		}
+	}
+}
+
+func TestListEnumeration(t *testing.T) {
+	cases := []Dimensions{{2, 2}, {2, 3}, {3, 3}}
+	filename := filepath.Join(t.TempDir(), "grids")
+	var out bytes.Buffer
+	if err := ListEnumeration(&out, cases, filename, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := make([][][]int, 0)
+	for _, d := range cases {
+		valid, _ := twoRegionGrids(d.Width, d.Height)
+		expected = append(expected, valid...)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	grids, err := parseGrids(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(grids, expected) {
+		t.Errorf("listed %d grids, expected %d in rank order", len(grids), len(expected))
+	}
+}
@@ -0,0 +1,167 @@
+package enumerate
+
+import (
+	"math/big"
+	"math/bits"
+	"sync"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// Counting modulo a prime p < 2^62 needs only uint64 arithmetic, and
+// no allocation.  With enough primes that their product exceeds every
+// possible count, the Chinese Remainder Theorem recovers the exact
+// count from its residues.  Each prime is counted separately, so they
+// can run in parallel.
+
+// Bounded is implemented by transfers which can say how many bits
+// the count of width x height grids may need.
+type Bounded interface {
+	CountBits(width int, height int) int
+}
+
+// There are at most 2^(wh) two-colored grids.
+func (twoColorTransfer) CountBits(width int, height int) int {
+	return width * height
+}
+
+func (t colorTransfer) CountBits(width int, height int) int {
+	return rulesCountBits(t.Rules, width, height)
+}
+
+func (t regionTransfer) CountBits(width int, height int) int {
+	return rulesCountBits(t.Rules, width, height)
+}
+
+// rulesCountBits bounds the count by the number of colorings.
+func rulesCountBits(rules *equiv.Rules, width int, height int) int {
+	return bits.Len(uint(rules.NumColors-1)) * rules.Lattice.RowCells(width) * height
+}
+
+// largePrimes returns the k largest primes below 2^62.
+func largePrimes(k int) []uint64 {
+	ret := make([]uint64, 0, k)
+	for p := uint64(1)<<62 - 1; len(ret) < k; p -= 2 {
+		if new(big.Int).SetUint64(p).ProbablyPrime(20) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// moduliFor returns enough primes to count up to 2^countBits.
+func moduliFor(countBits int) []uint64 {
+	// Each prime is more than 2^61.
+	return largePrimes(countBits/61 + 1)
+}
+
+func mulMod(a uint64, b uint64, p uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return bits.Rem64(hi, lo, p)
+}
+
+func addMod(a uint64, b uint64, p uint64) uint64 {
+	// Both are less than 2^62, so this cannot overflow.
+	s := a + b
+	if s >= p {
+		s -= p
+	}
+	return s
+}
+
+// crt returns the smallest nonnegative x with x = residues[i] mod
+// moduli[i], for every i.
+func crt(residues []uint64, moduli []uint64) *big.Int {
+	product := big.NewInt(1)
+	for _, p := range moduli {
+		product.Mul(product, new(big.Int).SetUint64(p))
+	}
+	x := big.NewInt(0)
+	for i, p := range moduli {
+		bp := new(big.Int).SetUint64(p)
+		rest := new(big.Int).Div(product, bp)
+		inverse := new(big.Int).ModInverse(new(big.Int).Mod(rest, bp), bp)
+		term := new(big.Int).SetUint64(residues[i])
+		term.Mul(term, inverse)
+		term.Mul(term, rest)
+		x.Add(x, term)
+	}
+	return x.Mod(x, product)
+}
+
+// modSuccessor is an EdgeClass with a small count.
+type modSuccessor struct {
+	Key   ClassKey
+	Count uint64
+}
+
+// UseModuli switches the map to counting modulo each of the primes,
+// instead of using CountByClass.  The counts must not use Weights.
+func (s *SuccessorMap) UseModuli(moduli []uint64) {
+	s.Moduli = moduli
+	s.Residues = make([]map[ClassKey]uint64, len(moduli))
+	for i, p := range moduli {
+		s.Residues[i] = make(map[ClassKey]uint64)
+		for k, v := range s.CountByClass {
+			s.Residues[i][k] = new(big.Int).Mod(v.Count, new(big.Int).SetUint64(p)).Uint64()
+		}
+	}
+	s.CountByClass = nil
+	s.modSuccessors = make(map[ClassKey][]modSuccessor)
+}
+
+// aggregateModular is the modular version of aggregate.
+func (s *SuccessorMap) aggregateModular() {
+	// The successors of every class are the same modulo every prime.
+	for k := range s.Residues[0] {
+		if _, ok := s.modSuccessors[k]; ok {
+			continue
+		}
+		successorsRaw, found := s.SuccessorCounts.Load(k)
+		if !found {
+			panic("Sucessors not found.")
+		}
+		successors := successorsRaw.([]EdgeClass)
+		ms := make([]modSuccessor, len(successors))
+		for i, successor := range successors {
+			n := successor.Count
+			if !n.IsUint64() {
+				panic("too many rows for modular counting")
+			}
+			ms[i] = modSuccessor{successor.Key, n.Uint64()}
+		}
+		s.modSuccessors[k] = ms
+	}
+
+	var wg sync.WaitGroup
+	for i := range s.Moduli {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := s.Moduli[i]
+			newCounts := make(map[ClassKey]uint64)
+			for k, count := range s.Residues[i] {
+				for _, successor := range s.modSuccessors[k] {
+					product := mulMod(count, successor.Count%p, p)
+					newCounts[successor.Key] = addMod(newCounts[successor.Key], product, p)
+				}
+			}
+			s.Residues[i] = newCounts
+		}(i)
+	}
+	wg.Wait()
+}
+
+// ValidCountModular reconstructs the number of valid grids from its
+// residues.
+func (s *SuccessorMap) ValidCountModular() *big.Int {
+	valid := make([]uint64, len(s.Moduli))
+	for i, p := range s.Moduli {
+		for k, count := range s.Residues[i] {
+			if _, present := s.ValidClasses.Load(k); present {
+				valid[i] = addMod(valid[i], count, p)
+			}
+		}
+	}
+	return crt(valid, s.Moduli)
+}
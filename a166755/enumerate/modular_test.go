@@ -0,0 +1,97 @@
+package enumerate
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+func TestLargePrimes(t *testing.T) {
+	primes := largePrimes(5)
+	low := new(big.Int).Lsh(big.NewInt(1), 61)
+	for i, p := range primes {
+		bp := new(big.Int).SetUint64(p)
+		if !bp.ProbablyPrime(20) || bp.Cmp(low) <= 0 || p >= 1<<62 {
+			t.Errorf("%v is not a prime between 2^61 and 2^62", p)
+		}
+		if i > 0 && p >= primes[i-1] {
+			t.Errorf("primes are not decreasing: %v", primes)
+		}
+	}
+
+	for _, countBits := range []int{1, 60, 61, 64, 122, 200} {
+		product := big.NewInt(1)
+		for _, p := range moduliFor(countBits) {
+			product.Mul(product, new(big.Int).SetUint64(p))
+		}
+		if product.BitLen() <= countBits {
+			t.Errorf("moduli for %d bits only reach %d bits", countBits, product.BitLen())
+		}
+	}
+}
+
+func TestCRT(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	moduli := largePrimes(3)
+	product := big.NewInt(1)
+	for _, p := range moduli {
+		product.Mul(product, new(big.Int).SetUint64(p))
+	}
+	for _, x := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		new(big.Int).Lsh(big.NewInt(1), 64),
+		new(big.Int).Sub(product, big.NewInt(1)),
+		new(big.Int).Rand(rnd, product),
+	} {
+		residues := make([]uint64, len(moduli))
+		for i, p := range moduli {
+			residues[i] = new(big.Int).Mod(x, new(big.Int).SetUint64(p)).Uint64()
+		}
+		if actual := crt(residues, moduli); actual.Cmp(x) != 0 {
+			t.Errorf("crt gave %v, expected %v", actual, x)
+		}
+	}
+}
+
+func TestCountRectangle_Modular(t *testing.T) {
+	ctx := context.Background()
+	unrestricted := &equiv.Rules{
+		NumColors:   3,
+		Boundary:    equiv.Plain,
+		Lattice:     equiv.Square,
+		Constraints: []equiv.Constraint{equiv.Unrestricted, equiv.Unrestricted, equiv.Unrestricted},
+	}
+	cases := []struct {
+		Rules *equiv.Rules
+		Dims  Dimensions
+	}{
+		{nil, Dimensions{5, 5}},
+		{nil, Dimensions{6, 6}},
+		{nil, Dimensions{5, 9}},
+		// Every 3-coloring, 3^44 of them, which is more than 2^64
+		{unrestricted, Dimensions{4, 11}},
+	}
+	for _, c := range cases {
+		exact, _, err := CountRectangle(ctx, c.Dims.Width, c.Dims.Height, Options{Rules: c.Rules})
+		if err != nil {
+			t.Fatal(err)
+		}
+		modular, _, err := CountRectangle(ctx, c.Dims.Width, c.Dims.Height, Options{Rules: c.Rules, Modular: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if modular.Cmp(exact) != 0 {
+			t.Errorf("%v: modular %v, exact %v", c.Dims, modular, exact)
+		}
+	}
+
+	all := new(big.Int).Exp(big.NewInt(3), big.NewInt(44), nil)
+	count, _, err := CountRectangle(ctx, 4, 11, Options{Rules: unrestricted, Modular: true})
+	if err != nil || count.Cmp(all) != 0 || count.IsUint64() {
+		t.Errorf("expected 3^44 = %v colorings, got %v, %v", all, count, err)
+	}
+}
@@ -0,0 +1,207 @@
+package enumerate
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// Polynomial is a count of grids, in which the coefficient p[k][m]
+// of x^k y^m is the number of grids with k black cells and an interface
+// of length m between the colors.  Transfers which count neither keep
+// a plain count instead; see EdgeClass.
+type Polynomial [][]*big.Int
+
+// Constant returns the polynomial n.
+func Constant(n int64) Polynomial {
+	return Polynomial{{big.NewInt(n)}}
+}
+
+// Monomial returns the polynomial x^k y^m.
+func Monomial(k int, m int) Polynomial {
+	p := make(Polynomial, k+1)
+	for i := range p {
+		p[i] = []*big.Int{}
+	}
+	p[k] = make([]*big.Int, m+1)
+	for j := range p[k] {
+		p[k][j] = big.NewInt(0)
+	}
+	p[k][m].SetInt64(1)
+	return p
+}
+
+// coeff returns the coefficient of x^k y^m, or nil if it is zero.
+func (p Polynomial) coeff(k int, m int) *big.Int {
+	if k < len(p) && m < len(p[k]) {
+		return p[k][m]
+	}
+	return nil
+}
+
+// zeros returns a polynomial with room for every term up to x^k y^m.
+func zeros(k int, m int) Polynomial {
+	z := make(Polynomial, k+1)
+	for i := range z {
+		z[i] = make([]*big.Int, m+1)
+		for j := range z[i] {
+			z[i][j] = big.NewInt(0)
+		}
+	}
+	return z
+}
+
+// degrees returns the largest powers of x and y.
+func (p Polynomial) degrees() (int, int) {
+	m := 0
+	for i := range p {
+		if len(p[i]) > m {
+			m = len(p[i])
+		}
+	}
+	return len(p) - 1, m - 1
+}
+
+// Plus returns p + q.
+func (p Polynomial) Plus(q Polynomial) Polynomial {
+	pk, pm := p.degrees()
+	qk, qm := q.degrees()
+	if qk > pk {
+		pk = qk
+	}
+	if qm > pm {
+		pm = qm
+	}
+	z := zeros(pk, pm)
+	for i := range z {
+		for j := range z[i] {
+			if c := p.coeff(i, j); c != nil {
+				z[i][j].Add(z[i][j], c)
+			}
+			if c := q.coeff(i, j); c != nil {
+				z[i][j].Add(z[i][j], c)
+			}
+		}
+	}
+	return z
+}
+
+// Times returns p * q.
+func (p Polynomial) Times(q Polynomial) Polynomial {
+	pk, pm := p.degrees()
+	qk, qm := q.degrees()
+	z := zeros(pk+qk, pm+qm)
+	var t big.Int
+	for i := range p {
+		for j := range p[i] {
+			if p[i][j].Sign() == 0 {
+				continue
+			}
+			for k := range q {
+				for m := range q[k] {
+					t.Mul(p[i][j], q[k][m])
+					z[i+k][j+m].Add(z[i+k][j+m], &t)
+				}
+			}
+		}
+	}
+	return z
+}
+
+// Total returns the number of grids, of any area or interface length.
+func (p Polynomial) Total() *big.Int {
+	total := big.NewInt(0)
+	for i := range p {
+		for _, c := range p[i] {
+			total.Add(total, c)
+		}
+	}
+	return total
+}
+
+// Terms calls f with every nonzero coefficient, in order of the
+// power of x and then y.
+func (p Polynomial) Terms(f func(k int, m int, c *big.Int)) {
+	for k := range p {
+		for m, c := range p[k] {
+			if c.Sign() != 0 {
+				f(k, m, c)
+			}
+		}
+	}
+}
+
+// String shows a constant as a plain number.
+func (p Polynomial) String() string {
+	if len(p) == 1 && len(p[0]) == 1 {
+		return p[0][0].String()
+	}
+	power := func(v string, n int) string {
+		switch n {
+		case 0:
+			return ""
+		case 1:
+			return v
+		}
+		return fmt.Sprintf("%v^%d", v, n)
+	}
+	terms := make([]string, 0)
+	p.Terms(func(k int, m int, c *big.Int) {
+		terms = append(terms, c.String()+power("x", k)+power("y", m))
+	})
+	if len(terms) == 0 {
+		return "0"
+	}
+	return strings.Join(terms, " + ")
+}
+
+// Weights says which statistics of each grid the Polynomial counts
+// keep track of.
+type Weights struct {
+	// The power of x is the number of black cells
+	Cells bool
+	// The power of y is the length of the interface
+	Interface bool
+}
+
+func (w Weights) Any() bool {
+	return w.Cells || w.Interface
+}
+
+// Row returns the weight of one new row: x^k y^m, if it has
+// k black cells and m sides between different colors, in the row or
+// touching the previous one (which is nil for the first row.)  Without
+// any Weights, it is nil.
+func (w Weights) Row(rules *equiv.Rules, prev []int, row []int) Polynomial {
+	if !w.Any() {
+		return nil
+	}
+	k := 0
+	if w.Cells {
+		for _, c := range row {
+			if c == 1 {
+				k += 1
+			}
+		}
+	}
+	m := 0
+	if w.Interface {
+		m = rules.InterfaceLength(prev, row)
+	}
+	return Monomial(k, m)
+}
+
+// Weighted is implemented by transfers which may count grids
+// by their black cells or interface.
+type Weighted interface {
+	Weights() Weights
+}
+
+func weightsOf(t Transfer) Weights {
+	if wt, ok := t.(Weighted); ok {
+		return wt.Weights()
+	}
+	return Weights{}
+}
@@ -0,0 +1,241 @@
+package enumerate
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// The broken-profile transfer counts two-region grids by adding one
+// cell at a time, rather than a whole row.  Its state is the frontier:
+// the lowest cell filled in so far in each column, so that columns to
+// the left of the next cell are one row further down than those to its
+// right.  Each cell has two choices, instead of every possible row.
+//
+// Like GridRectangle, the frontier records which cells are connected
+// by the cells above it.  A component which leaves the frontier is
+// finished, which is only allowed once, for a color with no other
+// cells on the frontier; that color may never be used again.
+
+// profileState is a frontier of up to MaxPackedCells columns.  Byte i
+// holds the color of column i plus one (or 0 if the column is still
+// empty) in its low two bits, and above them the label of its
+// component, numbered from 1 in order of first appearance.  The last
+// byte holds the color which was finished, plus one.
+type profileState [equiv.MaxPackedCells + 1]byte
+
+// frontier is the decoded form of a profileState.
+type frontier struct {
+	Colors []int
+	Labels []int
+
+	// The color which was finished, or -1.
+	Closed int
+}
+
+func (s profileState) decode(width int) frontier {
+	f := frontier{
+		Colors: make([]int, width),
+		Labels: make([]int, width),
+		Closed: int(s[equiv.MaxPackedCells]) - 1,
+	}
+	for i := range f.Colors {
+		f.Colors[i] = int(s[i]&3) - 1
+		f.Labels[i] = int(s[i] >> 2)
+	}
+	return f
+}
+
+// encode normalizes the frontier, so that the first color used is
+// white and the labels appear in order, since swapping the colors or
+// renaming the components does not change how the grid may continue.
+func (f frontier) encode() profileState {
+	var s profileState
+	swap := false
+	for _, c := range f.Colors {
+		if c >= 0 {
+			swap = c == 1
+			break
+		}
+	}
+	closed := f.Closed
+	if swap && closed >= 0 {
+		closed = 1 - closed
+	}
+	s[equiv.MaxPackedCells] = byte(closed + 1)
+
+	var relabel [equiv.MaxPackedCells + 1]int
+	next := 1
+	for i, c := range f.Colors {
+		if c < 0 {
+			continue
+		}
+		if relabel[f.Labels[i]] == 0 {
+			relabel[f.Labels[i]] = next
+			next += 1
+		}
+		if swap {
+			c = 1 - c
+		}
+		s[i] = byte(relabel[f.Labels[i]]<<2 | (c + 1))
+	}
+	return s
+}
+
+// place returns the state after coloring the cell in column i, or false
+// if the grid can no longer have exactly two regions.
+func (s profileState) place(width int, i int, c int) (profileState, bool) {
+	f := s.decode(width)
+	if c == f.Closed {
+		return s, false
+	}
+
+	// Does the cell above, which leaves the frontier, finish its component?
+	up, upLabel := f.Colors[i], f.Labels[i]
+	if up >= 0 && up != c {
+		remains := false
+		otherComponent := false
+		for j := range f.Colors {
+			if j == i || f.Colors[j] != up {
+				continue
+			}
+			if f.Labels[j] == upLabel {
+				remains = true
+			} else {
+				otherComponent = true
+			}
+		}
+		if !remains {
+			if f.Closed >= 0 || otherComponent {
+				return s, false
+			}
+			f.Closed = up
+		}
+	}
+
+	label := 0
+	for _, l := range f.Labels {
+		if l > label {
+			label = l
+		}
+	}
+	label += 1
+	if up == c {
+		label = upLabel
+	}
+	if i > 0 && f.Colors[i-1] == c {
+		left := f.Labels[i-1]
+		if up == c && left != upLabel {
+			for j := range f.Labels {
+				if f.Colors[j] >= 0 && f.Labels[j] == left {
+					f.Labels[j] = upLabel
+				}
+			}
+		} else if up != c {
+			label = left
+		}
+	}
+	f.Colors[i] = c
+	f.Labels[i] = label
+	return f.encode(), true
+}
+
+// valid checks whether a complete grid has exactly two regions.
+func (s profileState) valid(width int) bool {
+	f := s.decode(width)
+	labels := make(map[int]int)
+	for i, c := range f.Colors {
+		labels[f.Labels[i]] = c
+	}
+	if f.Closed >= 0 {
+		return len(labels) == 1
+	}
+	if len(labels) != 2 {
+		return false
+	}
+	colors := make(map[int]bool)
+	for _, c := range labels {
+		colors[c] = true
+	}
+	return len(colors) == 2
+}
+
+// ProfileTransfer counts grids of the given width with the
+// broken-profile transfer.
+type ProfileTransfer struct {
+	Width   int
+	Options Options
+
+	// The number of partial grids with each frontier
+	Counts map[profileState]*big.Int
+}
+
+func NewProfileTransfer(width int, opts Options) *ProfileTransfer {
+	if width > equiv.MaxPackedCells {
+		panic("too wide for the broken-profile transfer")
+	}
+	return &ProfileTransfer{
+		Width:   width,
+		Options: opts,
+		Counts:  map[profileState]*big.Int{{}: big.NewInt(1)},
+	}
+}
+
+// AddCell colors the cell in column i, both ways.
+func (p *ProfileTransfer) AddCell(i int) {
+	next := make(map[profileState]*big.Int)
+	for s, count := range p.Counts {
+		for c := 0; c <= 1; c++ {
+			s2, ok := s.place(p.Width, i, c)
+			if !ok {
+				continue
+			}
+			if exist, ok := next[s2]; ok {
+				exist.Add(exist, count)
+			} else {
+				next[s2] = new(big.Int).Set(count)
+			}
+		}
+	}
+	p.Counts = next
+}
+
+func (p *ProfileTransfer) ValidCount() *big.Int {
+	total := big.NewInt(0)
+	for s, count := range p.Counts {
+		if s.valid(p.Width) {
+			total.Add(total, count)
+		}
+	}
+	return total
+}
+
+// Run adds rows up to maxHeight, calling report with the count of
+// every rectangle, like SuccessorMap.Run.
+func (p *ProfileTransfer) Run(maxHeight int, report func(HeightResult)) {
+	p.RunContext(context.Background(), maxHeight, report)
+}
+
+// RunContext is like Run, but stops with the context's error once it
+// is done, checking before each height.
+func (p *ProfileTransfer) RunContext(ctx context.Context, maxHeight int, report func(HeightResult)) error {
+	for height := 1; height <= maxHeight; height++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := 0; i < p.Width; i++ {
+			p.AddCell(i)
+		}
+		if height > 1 {
+			p.Options.progressf(" Height=%d states=%d\n", height, len(p.Counts))
+		}
+		report(HeightResult{
+			Width:      p.Width,
+			Height:     height,
+			Count:      p.ValidCount(),
+			NumClasses: len(p.Counts),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package enumerate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// Ranker numbers the width x height grids with exactly two regions
+// from 0 to a(n)-1, in lexicographic order of their cells, read row by
+// row with white before black.
+//
+// Each row is chosen in turn, and the grids which start with a row
+// come before those which start with a later one.  So the rank of a
+// grid adds up, for each row, the number of ways to complete the grid
+// after every earlier choice of that row.
+type Ranker struct {
+	*transferHistory
+
+	// The number of ways to finish a grid of each class, at each
+	// height, starting with 1
+	completions []map[ClassKey]*big.Int
+}
+
+func NewRanker(width int, height int, opts Options) *Ranker {
+	r := &Ranker{
+		transferHistory: newTransferHistory(width, height, opts),
+		completions:     make([]map[ClassKey]*big.Int, height),
+	}
+	for h := height - 1; h >= 0; h-- {
+		r.completions[h] = make(map[ClassKey]*big.Int)
+		for k := range r.counts[h] {
+			n := big.NewInt(0)
+			if h == height-1 {
+				if _, valid := r.s.ValidClasses.Load(k); valid {
+					n.SetInt64(1)
+				}
+			} else {
+				successors, _ := r.s.SuccessorCounts.Load(k)
+				for _, e := range successors.([]EdgeClass) {
+					n.Add(n, new(big.Int).Mul(e.Count, r.completion(h+1, e.Key)))
+				}
+			}
+			r.completions[h][k] = n
+		}
+	}
+	return r
+}
+
+func (r *Ranker) completion(h int, key ClassKey) *big.Int {
+	if n, ok := r.completions[h][key]; ok {
+		return n
+	}
+	return big.NewInt(0)
+}
+
+// Rank returns the position of the grid, indexed by row and then
+// column, or an error if it does not have exactly two regions.
+func (r *Ranker) Rank(grid [][]int) (*big.Int, error) {
+	if len(grid) != r.Height {
+		return nil, fmt.Errorf("expected %d rows, not %d", r.Height, len(grid))
+	}
+	rank := big.NewInt(0)
+	var key ClassKey
+	var transform equiv.Transform
+	for h, row := range grid {
+		if len(row) != r.Width {
+			return nil, fmt.Errorf("expected %d columns, not %d", r.Width, len(row))
+		}
+		found := false
+		for _, ch := range r.choices(h, key, transform) {
+			if !lessRow(ch.Row, row) {
+				found = !lessRow(row, ch.Row)
+				key, transform = ch.Key, ch.Transform
+				break
+			}
+			rank.Add(rank, r.completion(h, ch.Key))
+		}
+		if !found || r.completion(h, key).Sign() == 0 {
+			return nil, fmt.Errorf("not a grid with two regions")
+		}
+	}
+	return rank, nil
+}
+
+// Unrank returns the grid at position i.
+func (r *Ranker) Unrank(i *big.Int) ([][]int, error) {
+	if i.Sign() < 0 || i.Cmp(r.Total) >= 0 {
+		return nil, fmt.Errorf("%v is not between 0 and %v", i, r.Total)
+	}
+	remaining := new(big.Int).Set(i)
+	grid := make([][]int, r.Height)
+	var key ClassKey
+	var transform equiv.Transform
+	for h := range grid {
+		for _, ch := range r.choices(h, key, transform) {
+			n := r.completion(h, ch.Key)
+			if remaining.Cmp(n) < 0 {
+				grid[h] = ch.Row
+				key, transform = ch.Key, ch.Transform
+				break
+			}
+			remaining.Sub(remaining, n)
+		}
+	}
+	return grid, nil
+}
+
+// Rank returns the position of an n x n grid among all those with
+// exactly two regions.
+func Rank(grid [][]int) (*big.Int, error) {
+	if len(grid) == 0 {
+		return nil, fmt.Errorf("empty grid")
+	}
+	return NewRanker(len(grid[0]), len(grid), Options{}).Rank(grid)
+}
+
+// Unrank returns the n x n grid with exactly two regions at position i.
+func Unrank(n int, i *big.Int) ([][]int, error) {
+	return NewRanker(n, n, Options{}).Unrank(i)
+}
+
+// parseGrids reads grids drawn as by showGrid, separated by blank
+// lines.  Lines with * or = are ignored, so that the output of -sample
+// or -unrank can be read back.
+func parseGrids(f *os.File) ([][][]int, error) {
+	grids := make([][][]int, 0)
+	var grid [][]int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.ContainsAny(line, "*=") {
+			continue
+		}
+		if line == "" {
+			if grid != nil {
+				grids = append(grids, grid)
+				grid = nil
+			}
+			continue
+		}
+		row := make([]int, len(line))
+		for x, c := range line {
+			switch c {
+			case '.':
+				row[x] = 0
+			case 'X':
+				row[x] = 1
+			default:
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+		grid = append(grid, row)
+	}
+	if grid != nil {
+		grids = append(grids, grid)
+	}
+	return grids, scanner.Err()
+}
+
+// RankEnumeration writes the rank of every grid in the file to out.
+func RankEnumeration(out io.Writer, filename string, opts Options) error {
+	if err := opts.checkTwoColorMethod("RankEnumeration"); err != nil {
+		return err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	grids, err := parseGrids(f)
+	if err != nil {
+		return fmt.Errorf("couldn't parse %v: %v", filename, err)
+	}
+
+	rankers := make(map[Dimensions]*Ranker)
+	for _, grid := range grids {
+		d := Dimensions{len(grid[0]), len(grid)}
+		if _, ok := rankers[d]; !ok {
+			rankers[d] = NewRanker(d.Width, d.Height, opts)
+		}
+		rank, err := rankers[d].Rank(grid)
+		if err != nil {
+			fmt.Fprintf(out, "%v  %v\n\n", showGrid(grid), err)
+		} else {
+			fmt.Fprintf(out, "%v  rank=%v\n\n", showGrid(grid), rank)
+		}
+	}
+	return nil
+}
+
+// UnrankEnumeration writes the grids at each position given to out.
+func UnrankEnumeration(out io.Writer, cases []Dimensions, positions []*big.Int, opts Options) error {
+	if err := opts.checkTwoColorMethod("UnrankEnumeration"); err != nil {
+		return err
+	}
+	for _, d := range cases {
+		r := NewRanker(d.Width, d.Height, opts)
+		if d.IsSquare() {
+			fmt.Fprintf(out, "**** N=%v | grids=%v\n\n", d.Width, r.Total)
+		} else {
+			fmt.Fprintf(out, "**** %v | grids=%v\n\n", d, r.Total)
+		}
+		for _, i := range positions {
+			grid, err := r.Unrank(i)
+			if err != nil {
+				fmt.Fprintf(out, "  %v\n\n", err)
+				continue
+			}
+			fmt.Fprintf(out, "%v  rank=%v\n\n", showGrid(grid), i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package enumerate
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// flattenGrid reads the cells row by row.
+func flattenGrid(grid [][]int) []int {
+	ret := make([]int, 0)
+	for _, row := range grid {
+		ret = append(ret, row...)
+	}
+	return ret
+}
+
+func copyGrid(grid [][]int) [][]int {
+	ret := make([][]int, len(grid))
+	for y := range grid {
+		ret[y] = append([]int{}, grid[y]...)
+	}
+	return ret
+}
+
+// twoRegionGrids returns every width x height grid with exactly two
+// regions, in lexicographic order, and every other grid.
+func twoRegionGrids(width int, height int) ([][][]int, [][][]int) {
+	board := equiv.Board{Width: width, Height: height}
+	valid := make([][][]int, 0)
+	invalid := make([][][]int, 0)
+	forEachGrid(width, height, func(grid [][]int) {
+		cells := combinations.IndicatorMap{Values: flattenGrid(grid)}
+		if hasOneRegionPerColor(board, squareRules, cells) {
+			valid = append(valid, copyGrid(grid))
+		} else {
+			invalid = append(invalid, copyGrid(grid))
+		}
+	})
+	sort.Slice(valid, func(i, j int) bool {
+		return lessRow(flattenGrid(valid[i]), flattenGrid(valid[j]))
+	})
+	return valid, invalid
+}
+
+func TestRanker_Bijection(t *testing.T) {
+	for _, d := range []Dimensions{{1, 2}, {2, 2}, {2, 3}, {3, 2}, {3, 3}, {4, 3}, {3, 4}, {4, 4}} {
+		valid, invalid := twoRegionGrids(d.Width, d.Height)
+		r := NewRanker(d.Width, d.Height, Options{})
+		if r.Total.Cmp(big.NewInt(int64(len(valid)))) != 0 {
+			t.Fatalf("%v: %v grids ranked, expected %d", d, r.Total, len(valid))
+		}
+
+		for i, grid := range valid {
+			rank, err := r.Rank(grid)
+			if err != nil {
+				t.Fatalf("%v: couldn't rank %v: %v", d, grid, err)
+			}
+			if rank.Cmp(big.NewInt(int64(i))) != 0 {
+				t.Errorf("%v: %v has rank %v, expected %d", d, grid, rank, i)
+			}
+			unranked, err := r.Unrank(big.NewInt(int64(i)))
+			if err != nil {
+				t.Fatalf("%v: couldn't unrank %d: %v", d, i, err)
+			}
+			if !reflect.DeepEqual(unranked, grid) {
+				t.Errorf("%v: unrank %d gave %v, expected %v", d, i, unranked, grid)
+			}
+		}
+
+		for _, grid := range invalid {
+			if rank, err := r.Rank(grid); err == nil {
+				t.Errorf("%v: %v has rank %v, but does not have two regions", d, grid, rank)
+			}
+		}
+	}
+}
+
+func TestRanker_OutOfRange(t *testing.T) {
+	r := NewRanker(3, 3, Options{})
+	for _, i := range []*big.Int{big.NewInt(-1), r.Total, new(big.Int).Add(r.Total, big.NewInt(1))} {
+		if _, err := r.Unrank(i); err == nil {
+			t.Errorf("expected an error unranking %v of %v", i, r.Total)
+		}
+	}
+	for _, grid := range [][][]int{
+		{{0, 1, 1}, {0, 0, 1}},
+		{{0, 1}, {0, 1}, {0, 1}},
+	} {
+		if _, err := r.Rank(grid); err == nil {
+			t.Errorf("expected an error ranking %v in a 3x3 grid", grid)
+		}
+	}
+}
@@ -0,0 +1,951 @@
+package enumerate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// RowClass is an equivalence class of grids, identified by their
+// last row, such as equiv.GridRectangle.
+type RowClass interface {
+	Key() string
+	Plot() string
+	Rows() int
+}
+
+// ClassKey identifies a RowClass.  Classes with a compact encoding
+// use Packed, which is faster to hash and compare, and others use
+// their Key.
+type ClassKey struct {
+	Packed equiv.PackedKey
+	Long   string
+}
+
+// Packer is implemented by classes which may have a PackedKey.
+type Packer interface {
+	PackedKey() (equiv.PackedKey, bool)
+}
+
+func keyOf(c interface{ Key() string }) ClassKey {
+	if p, ok := c.(Packer); ok {
+		if packed, ok := p.PackedKey(); ok {
+			return ClassKey{Packed: packed}
+		}
+	}
+	return ClassKey{Long: c.Key()}
+}
+
+func (k ClassKey) String() string {
+	if k.Long != "" {
+		return k.Long
+	}
+	return fmt.Sprintf("%x", k.Packed[:])
+}
+
+// Less orders the keys, in no particular way.
+func (k ClassKey) Less(other ClassKey) bool {
+	if k.Long != other.Long {
+		return k.Long < other.Long
+	}
+	return bytes.Compare(k.Packed[:], other.Packed[:]) < 0
+}
+
+// Transfer describes a problem that can be solved by adding one row
+// at a time: which classes the first row falls into, the successors
+// of each class, and which classes contain the grids we are counting.
+type Transfer interface {
+	StartingClasses(width int) map[ClassKey]EdgeClass
+	Children(c RowClass) []EdgeClass
+	IsValid(c RowClass) bool
+}
+
+// transpose returns the dimensions to enumerate, along the longer side
+// unless the transfer's rules are Oriented.
+func transpose(t Transfer, d Dimensions) Dimensions {
+	if rules := rulesOf(t); rules != nil && rules.Oriented() {
+		return d
+	}
+	return d.Transposed()
+}
+
+// RegionCounter is implemented by transfers which count grids
+// with varying numbers of regions.
+type RegionCounter interface {
+	NumRegions(c RowClass) int
+}
+
+type EdgeClass struct {
+	Key   ClassKey
+	Class RowClass
+
+	// The number of grids in the class
+	Count *big.Int
+
+	// Only with Weights, the number of grids by each statistic, of
+	// which Count is the Total
+	Distribution Polynomial
+}
+
+func NewEdgeClass1(key ClassKey, c RowClass) EdgeClass {
+	return NewEdgeClass(key, c, nil)
+}
+
+// NewEdgeClass returns a class with one member, counted with the weight
+// w, which is not copied, or without Weights if w is nil.
+func NewEdgeClass(key ClassKey, c RowClass, w Polynomial) EdgeClass {
+	return EdgeClass{
+		Key:          key,
+		Class:        c,
+		Count:        big.NewInt(1),
+		Distribution: w,
+	}
+}
+
+// NewEdgeClassProduct returns a class with as many members as n1
+// times n2.
+func NewEdgeClassProduct(key ClassKey, c RowClass, n1 EdgeClass, n2 EdgeClass) EdgeClass {
+	e := EdgeClass{
+		Key:   key,
+		Class: c,
+		Count: new(big.Int).Mul(n1.Count, n2.Count),
+	}
+	if n1.Distribution != nil {
+		e.Distribution = n1.Distribution.Times(n2.Distribution)
+	}
+	return e
+}
+
+func (e EdgeClass) Inc1() EdgeClass {
+	return e.Inc(nil)
+}
+
+// Inc adds one more member to the class, with the weight w, or without
+// Weights if w is nil.
+func (e EdgeClass) Inc(w Polynomial) EdgeClass {
+	ret := EdgeClass{
+		Key:   e.Key,
+		Class: e.Class,
+		Count: new(big.Int).Add(e.Count, big.NewInt(1)),
+	}
+	if w != nil {
+		ret.Distribution = e.Distribution.Plus(w)
+	}
+	return ret
+}
+
+// IncProduct adds as many members as n1 times n2.
+func (e EdgeClass) IncProduct(n1 EdgeClass, n2 EdgeClass) EdgeClass {
+	z := new(big.Int).Mul(n1.Count, n2.Count)
+	ret := EdgeClass{
+		Key:   e.Key,
+		Class: e.Class,
+		Count: z.Add(z, e.Count),
+	}
+	if n1.Distribution != nil {
+		ret.Distribution = e.Distribution.Plus(n1.Distribution.Times(n2.Distribution))
+	}
+	return ret
+}
+
+type SuccessorMap struct {
+	Width    int
+	Transfer Transfer
+
+	// How to run the enumeration
+	Options Options
+
+	// The height of the grids counted so far
+	Height int
+
+	// Map from key to all the successors of the edge class
+	//SuccessorCounts map[ClassKey][]EdgeClass
+	SuccessorCounts sync.Map
+
+	// Newly introduced classes for which no successor is known
+	NewClasses []RowClass
+
+	// next version of NewClasses
+	NextClasses sync.Map
+
+	// For the current height, which classes are present
+	// and how many members do they have?
+	CountByClass map[ClassKey]EdgeClass
+
+	// Valid classes only
+	ValidClasses sync.Map
+
+	// Every class seen so far, by key, if keepClasses is set; see
+	// KeepClasses.
+	Classes     sync.Map
+	keepClasses bool
+
+	// If not empty, the counts are kept modulo each of these primes
+	// in Residues, instead of in CountByClass; see UseModuli.
+	Moduli        []uint64
+	Residues      []map[ClassKey]uint64
+	modSuccessors map[ClassKey][]modSuccessor
+
+	// If not nil, the counts and successors are kept on disk, instead
+	// of in CountByClass and SuccessorCounts; see UseDisk.
+	Disk *diskStore
+}
+
+func (s *SuccessorMap) CheckValid(key ClassKey, c RowClass) {
+	if s.Transfer.IsValid(c) {
+		s.ValidClasses.Store(key, struct{}{})
+	}
+}
+
+// twoColorTransfer counts grids with exactly two regions, one black
+// and one white.
+type twoColorTransfer struct {
+	// Used by both colors
+	Adjacency equiv.Adjacency
+
+	// If not nil, only rows for which this is true may be used.
+	Rows func(row []int) bool
+
+	// Whether to count the grids by the length of their interface,
+	// which does not change when the colors are interchanged.
+	CountInterface bool
+}
+
+// squareRules describe the grids counted by twoColorTransfer, as far
+// as the length of the interface is concerned.
+var squareRules = &equiv.Rules{NumColors: 2, Boundary: equiv.Plain, Lattice: equiv.Square}
+
+func (t twoColorTransfer) Weights() Weights {
+	return Weights{Interface: t.CountInterface}
+}
+
+func (t twoColorTransfer) StartingClasses(width int) map[ClassKey]EdgeClass {
+	if t.Adjacency == equiv.Eight || t.Rows != nil || t.CountInterface {
+		return everyStartingClass(width, t.Adjacency, t.Rows, t.Weights())
+	}
+	return startingClasses(width)
+}
+
+func (t twoColorTransfer) Children(c RowClass) []EdgeClass {
+	return EnumerateRectangleChildren(c.(*equiv.GridRectangle), t.Rows, t.Weights())
+}
+
+func (twoColorTransfer) IsValid(c RowClass) bool {
+	gr := c.(*equiv.GridRectangle)
+	numPartitions := len(gr.White.Sets) + len(gr.Black.Sets)
+	return numPartitions == 2 || (numPartitions == 1 && !gr.SolidColor)
+}
+
+// EnumerateRectangleChildren returns the classes which follow gr,
+// using only the rows allowed (or all of them, if allowed is nil.)
+// The weights may not include the black cells, since black and white
+// are interchanged.
+func EnumerateRectangleChildren(gr *equiv.GridRectangle, allowed func(row []int) bool, weights Weights) []EdgeClass {
+	prev := gr.EdgeColors()
+	byKey := make(map[ClassKey]EdgeClass)
+	rectangleRows(gr, func(row []int) {
+		if allowed != nil && !allowed(row) {
+			return
+		}
+		child := gr.Expand(row)
+		key := keyOf(child)
+		n := weights.Row(squareRules, prev, row)
+		if exist, ok := byKey[key]; ok {
+			byKey[key] = exist.Inc(n)
+		} else {
+			byKey[key] = NewEdgeClass(key, child, n)
+		}
+	})
+
+	ret := make([]EdgeClass, 0, len(byKey))
+	for _, v := range byKey {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// rectangleRows calls add with every row which could follow gr
+// without leaving a region behind.  The row is only valid until
+// add returns.
+func rectangleRows(gr *equiv.GridRectangle, add func(row []int)) {
+	config := combinations.IndicatorConfig{
+		Size:   gr.Width,
+		Offset: 0,
+	}
+	white := gr.White.Sets
+	black := gr.Black.Sets
+
+	// If the existing border is a single color, but the grid is
+	// not monochromatic, then there is only one way to expand it.
+	if len(black) == 0 && !gr.SolidColor {
+		add(make([]int, gr.Width))
+		return
+	}
+
+	// If there is a single group of one color, we can create a solid
+	// border of the other color.
+	if len(white) == 1 {
+		allOnes := make([]int, gr.Width)
+		for i := range allOnes {
+			allOnes[i] = 1
+		}
+		add(allOnes)
+	}
+
+	if len(black) == 1 {
+		add(make([]int, gr.Width))
+	}
+
+	// With 8-connectivity, a set may also be extended diagonally, so
+	// the choices for different sets overlap.  Try every border
+	// and keep the ones which leave no set behind.
+	if gr.Adjacency == equiv.Eight {
+		gens := make([]combinations.SetGenerator, gr.Width)
+		for i := range gens {
+			gens[i] = &combinations.FreeChoice{config, i}
+		}
+		ch := make(chan combinations.IndicatorMap)
+		go combinations.Product(gens, ch)
+
+		for boundary := range ch {
+			if gr.Continues(boundary.Values) {
+				add(boundary.Values)
+			}
+		}
+		return
+	}
+
+	// Otherwise, the new border should preserve any existing
+	// single-colored region, to avoid leaving it disconnected.
+	// At least one square should be extended to the new border,
+	// for each set.
+	gens := make([]combinations.SetGenerator, 0, len(white)+len(black))
+
+	for _, s := range white {
+		gens = append(gens, &combinations.MandatoryZero{config, s})
+	}
+	for _, s := range black {
+		gens = append(gens, &combinations.MandatoryOne{config, s})
+	}
+
+	ch := make(chan combinations.IndicatorMap)
+	go combinations.Product(gens, ch)
+
+	for boundary := range ch {
+		add(boundary.Values)
+	}
+}
+
+// We could accumulate all the results (successor counts and new functions) and have
+// the originator put them all in the map, but I think the map is good enough for the
+// scale we're working at.
+//
+// Worker returns the first error from the disk store, if any, but keeps
+// taking classes off the queue until it is closed.
+func (s *SuccessorMap) Worker(height int, workQueue <-chan RowClass) error {
+	var err error
+	for c := range workQueue {
+		if err != nil {
+			continue
+		}
+		cKey := keyOf(c)
+		s.Options.verbosef("Expanding %v %v\n", c.Plot(), cKey)
+		expansions := s.Transfer.Children(c)
+
+		for i, e := range expansions {
+			if _, ok := s.SuccessorCounts.Load(e.Key); !ok {
+				if e.Class.Rows() != height {
+					panic("new class lacks correct height")
+				}
+				s.NextClasses.Store(e.Key, e.Class)
+				if s.keepClasses {
+					s.Classes.Store(e.Key, e.Class)
+				}
+				s.CheckValid(e.Key, e.Class)
+				s.Options.verbosef(" %v %v %v NEW\n", e.Class.Plot(), e.Count, e.Key)
+			} else {
+				s.Options.verbosef(" %v %v %v\n", e.Class.Plot(), e.Count, e.Key)
+			}
+			// Throw away the class itself, so that we're normalized on
+			// what remains in NextClasses
+			expansions[i].Class = nil
+		}
+
+		if s.Disk != nil {
+			if err = s.Disk.AddSuccessors(cKey, expansions); err != nil {
+				continue
+			}
+			expansions = nil
+		}
+
+		// Store the low-cost list
+		s.SuccessorCounts.Store(keyOf(c), expansions)
+
+	}
+	return err
+}
+
+// Iterate finds the successors of the new classes, and the counts at
+// the given height.  It only fails if the counts are kept on disk.
+func (s *SuccessorMap) Iterate(height int) error {
+	for _, c := range s.NewClasses {
+		// Placeholder so that we don't trigger NEW again
+		s.SuccessorCounts.Store(keyOf(c), []EdgeClass{})
+	}
+
+	workQueue := make(chan RowClass, 100)
+	var wg sync.WaitGroup
+
+	errs := make([]error, s.Options.numWorkers())
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Worker(height, workQueue)
+		}(i)
+	}
+
+	for _, c := range s.NewClasses {
+		workQueue <- c
+	}
+	close(workQueue)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	s.NewClasses = make([]RowClass, 0)
+	s.NextClasses.Range(func(k, v interface{}) bool {
+		s.NewClasses = append(s.NewClasses, v.(RowClass))
+		s.NextClasses.Delete(k)
+		return true
+	})
+
+	numClasses := 0
+	s.SuccessorCounts.Range(func(k, v interface{}) bool {
+		numClasses += 1
+		return true
+	})
+	s.Options.progressf(" Height=%d classes=%d+%d\n", height, numClasses, len(s.NewClasses))
+
+	if len(s.Moduli) > 0 {
+		s.aggregateModular()
+	} else if s.Disk != nil {
+		if err := s.Disk.Aggregate(); err != nil {
+			return err
+		}
+	} else {
+		s.aggregate(height)
+	}
+	s.Height = height
+	return nil
+}
+
+// aggregate counts the members of each class at the next height.
+func (s *SuccessorMap) aggregate(height int) {
+	newCounts := make(map[ClassKey]EdgeClass)
+	for k, startClass := range s.CountByClass {
+		successorsRaw, found := s.SuccessorCounts.Load(k)
+		if !found {
+			panic("Sucessors not found.")
+		}
+		successors := successorsRaw.([]EdgeClass)
+		for _, successor := range successors {
+			k2 := successor.Key
+			if exist, ok := newCounts[k2]; ok {
+				newCounts[k2] = exist.IncProduct(startClass, successor)
+			} else {
+				newCounts[k2] = NewEdgeClassProduct(k2, successor.Class, startClass, successor)
+			}
+		}
+	}
+
+	s.CountByClass = newCounts
+	if s.Options.Verbose {
+		s.Options.progressf("\nCounts at height %d:\n", height)
+		for key, val := range newCounts {
+			// The successors do not keep their classes, and those
+			// from before a checkpoint may not have been kept.
+			plot := "?"
+			if c, ok := s.Classes.Load(key); ok {
+				plot = c.(RowClass).Plot()
+			}
+			s.Options.progressf(" %v %v %v\n", plot, val.Count, key)
+		}
+		s.Options.progressf("\n")
+	}
+}
+
+func startingClasses(width int) map[ClassKey]EdgeClass {
+	// Only
+	//  wwwwwwwww / bbbbbbbbb
+	// or
+	//  wwwwwbbbb
+	// or
+	//  wbbbbbwww
+	// can be be a valid first row. If there are more than three
+	// groups, then not all of them can be connected later (without
+	// violating planarity!)
+	//
+	// This also holds if one color is 8-connected and the other
+	// 4-connected, but if both are 8-connected, the regions may
+	// cross diagonally; see everyStartingClass.
+
+	byKey := make(map[ClassKey]EdgeClass)
+	add := func(gr *equiv.GridRectangle) {
+		key := keyOf(gr)
+		if exist, ok := byKey[key]; ok {
+			byKey[key] = exist.Inc1()
+		} else {
+			byKey[key] = NewEdgeClass1(key, gr)
+		}
+	}
+
+	// Counting separately to make it easier to avoid double-counting.
+	// First case; solid color
+	set1 := make([]int, 0, width)
+	for i := 0; i < width; i++ {
+		set1 = append(set1, i)
+	}
+	a := &equiv.GridRectangle{
+		Width:      width,
+		Height:     1,
+		SolidColor: true,
+		White:      equiv.EdgePartition{[][]int{set1}},
+		Black:      equiv.EdgePartition{[][]int{}},
+	}
+	a.MakeCanonical()
+	byKey[keyOf(a)] = EdgeClass{Key: keyOf(a), Class: a, Count: big.NewInt(2)}
+
+	// Second case: two colors
+	// Some of these map to the same class, i.e., aab and bba
+	for right := 1; right < width; right++ {
+		set1 := make([]int, right)
+		set2 := make([]int, width-right)
+		for i := 0; i < right; i++ {
+			set1[i] = i
+		}
+		for i := right; i < width; i++ {
+			set2[i-right] = i
+		}
+
+		a := &equiv.GridRectangle{
+			Width:      width,
+			Height:     1,
+			SolidColor: false,
+			White:      equiv.EdgePartition{[][]int{set1}},
+			Black:      equiv.EdgePartition{[][]int{set2}},
+		}
+		a.MakeCanonical()
+		add(a)
+
+		b := &equiv.GridRectangle{
+			Width:      width,
+			Height:     1,
+			SolidColor: false,
+			White:      equiv.EdgePartition{[][]int{set2}},
+			Black:      equiv.EdgePartition{[][]int{set1}},
+		}
+		b.MakeCanonical()
+		add(b)
+	}
+
+	// Third case: white-black-white or black-white-black
+	// these cannot alias under color inversion, but might under mirror image.
+	for left := 1; left < width-1; left++ {
+		for right := left + 1; right < width; right++ {
+			set1 := make([]int, left)
+			set2 := make([]int, right-left)
+			set3 := make([]int, width-right)
+			for i := 0; i < left; i++ {
+				set1[i] = i
+			}
+			for i := left; i < right; i++ {
+				set2[i-left] = i
+			}
+			for i := right; i < width; i++ {
+				set3[i-right] = i
+			}
+			a := &equiv.GridRectangle{
+				Width:      width,
+				Height:     1,
+				SolidColor: false,
+				White:      equiv.EdgePartition{[][]int{set1, set3}},
+				Black:      equiv.EdgePartition{[][]int{set2}},
+			}
+			a.MakeCanonical()
+			add(a)
+
+			b := &equiv.GridRectangle{
+				Width:      width,
+				Height:     1,
+				SolidColor: false,
+				White:      equiv.EdgePartition{[][]int{set2}},
+				Black:      equiv.EdgePartition{[][]int{set1, set3}},
+			}
+			b.MakeCanonical()
+			add(b)
+		}
+	}
+
+	return byKey
+}
+
+// everyStartingClass returns the classes of every possible first row
+// (or every allowed one, if allowed is not nil), without assuming that
+// the regions cannot cross.
+func everyStartingClass(width int, adjacency equiv.Adjacency, allowed func(row []int) bool, weights Weights) map[ClassKey]EdgeClass {
+	byKey := make(map[ClassKey]EdgeClass)
+	board := equiv.Board{
+		Width:     width,
+		Height:    1,
+		Adjacency: []equiv.Adjacency{adjacency, adjacency},
+	}
+	config := combinations.IndicatorConfig{Size: width, Offset: 0}
+	gens := make([]combinations.SetGenerator, width)
+	for i := range gens {
+		gens[i] = &combinations.FreeChoice{config, i}
+	}
+	for _, row := range combinations.ProductList(gens) {
+		if allowed != nil && !allowed(row.Values) {
+			continue
+		}
+		gr := equiv.RectangleClassForBoard(board, [][]int{row.Values})
+		gr.MakeCanonical()
+		key := keyOf(gr)
+		n := weights.Row(squareRules, nil, row.Values)
+		if exist, ok := byKey[key]; ok {
+			byKey[key] = exist.Inc(n)
+		} else {
+			byKey[key] = NewEdgeClass(key, gr, n)
+		}
+	}
+	return byKey
+}
+
+func (s *SuccessorMap) ValidCount() *big.Int {
+	total := big.NewInt(0)
+
+	for k, v := range s.CountByClass {
+		if _, present := s.ValidClasses.Load(k); present {
+			total.Add(total, v.Count)
+		}
+	}
+	return total
+}
+
+// ValidDistribution is like ValidCount, by each statistic counted; it
+// is only used with Weights.
+func (s *SuccessorMap) ValidDistribution() Polynomial {
+	total := Constant(0)
+
+	for k, v := range s.CountByClass {
+		if _, present := s.ValidClasses.Load(k); present {
+			total = total.Plus(v.Distribution)
+		}
+	}
+	return total
+}
+
+// CountByRegions totals the valid classes by their number of regions;
+// the result is indexed by the number of regions.
+func (s *SuccessorMap) CountByRegions(rc RegionCounter) []*big.Int {
+	byRegions := make([]*big.Int, 0)
+	for k, v := range s.CountByClass {
+		if _, present := s.ValidClasses.Load(k); !present {
+			continue
+		}
+		c, _ := s.Classes.Load(k)
+		n := rc.NumRegions(c.(RowClass))
+		for len(byRegions) <= n {
+			byRegions = append(byRegions, big.NewInt(0))
+		}
+		byRegions[n].Add(byRegions[n], v.Count)
+	}
+	return byRegions
+}
+
+// needsClasses reports whether the map must keep every class to count
+// the grids, or to show them.
+func needsClasses(t Transfer, opts Options) bool {
+	_, counted := t.(RegionCounter)
+	return counted || opts.Verbose
+}
+
+func NewSuccessorMap(t Transfer, width int, opts Options) *SuccessorMap {
+	firstRow := t.StartingClasses(width)
+	if opts.Verbose {
+		opts.progressf("Initial row:\n")
+		for key, val := range firstRow {
+			opts.progressf(" %v %v %v\n", val.Class.Plot(), val.Count, key)
+		}
+	}
+
+	s := &SuccessorMap{
+		Width:        width,
+		Height:       1,
+		Transfer:     t,
+		Options:      opts,
+		NewClasses:   make([]RowClass, 0, len(firstRow)),
+		CountByClass: firstRow,
+		keepClasses:  needsClasses(t, opts),
+	}
+
+	for _, v := range firstRow {
+		s.NewClasses = append(s.NewClasses, v.Class)
+		if s.keepClasses {
+			s.Classes.Store(v.Key, v.Class)
+		}
+		s.CheckValid(v.Key, v.Class)
+	}
+	return s
+}
+
+// KeepClasses keeps every class in Classes, for callers which look
+// them up by key.  Otherwise only the new classes are kept, and only
+// until their successors are found.  It must be called before Run.
+func (s *SuccessorMap) KeepClasses() {
+	s.keepClasses = true
+	for _, c := range s.NewClasses {
+		s.Classes.Store(keyOf(c), c)
+	}
+}
+
+// HeightResult is the count for one w x h rectangle.
+type HeightResult struct {
+	Width      int
+	Height     int
+	Count      *big.Int
+	NumClasses int
+
+	// Only if the transfer is a RegionCounter
+	ByRegions []*big.Int
+
+	// Only if the transfer is Weighted, and counts something
+	Distribution Polynomial
+}
+
+// Result totals the valid classes at the given height.  It only fails
+// if the counts are kept on disk.
+func (s *SuccessorMap) Result(height int) (HeightResult, error) {
+	if len(s.Moduli) > 0 {
+		return HeightResult{
+			Width:      s.Width,
+			Height:     height,
+			Count:      s.ValidCountModular(),
+			NumClasses: len(s.Residues[0]),
+		}, nil
+	}
+
+	if s.Disk != nil {
+		count, err := s.Disk.ValidCount(func(k ClassKey) bool {
+			_, present := s.ValidClasses.Load(k)
+			return present
+		})
+		if err != nil {
+			return HeightResult{}, err
+		}
+		return HeightResult{
+			Width:      s.Width,
+			Height:     height,
+			Count:      count,
+			NumClasses: s.Disk.NumCounts,
+		}, nil
+	}
+
+	r := HeightResult{
+		Width:      s.Width,
+		Height:     height,
+		Count:      s.ValidCount(),
+		NumClasses: len(s.CountByClass),
+	}
+	if rc, ok := s.Transfer.(RegionCounter); ok {
+		r.ByRegions = s.CountByRegions(rc)
+	}
+	if weightsOf(s.Transfer).Any() {
+		r.Distribution = s.ValidDistribution()
+	}
+	return r, nil
+}
+
+// Run iterates up to maxHeight, calling report with the count of
+// every intermediate rectangle (including height 1.)  It only fails
+// if the counts are kept on disk.
+func (s *SuccessorMap) Run(maxHeight int, report func(HeightResult)) error {
+	return s.RunContext(context.Background(), maxHeight, report)
+}
+
+// RunContext is like Run, but stops with the context's error once it
+// is done, checking before each height.
+func (s *SuccessorMap) RunContext(ctx context.Context, maxHeight int, report func(HeightResult)) error {
+	r, err := s.Result(s.Height)
+	if err != nil {
+		return err
+	}
+	report(r)
+	for height := s.Height + 1; height <= maxHeight; height++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.Iterate(height); err != nil {
+			return err
+		}
+		r, err := s.Result(height)
+		if err != nil {
+			return err
+		}
+		report(r)
+	}
+	return nil
+}
+
+// countWidth enumerates one width up to maxHeight, with the profile
+// transfer if opts.Profile is set, and otherwise with runSuccessorMap.
+func countWidth(ctx context.Context, t Transfer, width int, maxHeight int, resumed *checkpoint, opts Options, report func(HeightResult)) error {
+	if opts.Profile {
+		return NewProfileTransfer(width, opts).RunContext(ctx, maxHeight, report)
+	}
+	return runSuccessorMap(ctx, t, width, maxHeight, resumed, opts, report)
+}
+
+// runSuccessorMap enumerates one width up to maxHeight, continuing
+// from the checkpoint if it has the same width, and saving a new one
+// after every height if opts.Checkpoint is set.
+func runSuccessorMap(ctx context.Context, t Transfer, width int, maxHeight int, resumed *checkpoint, opts Options, report func(HeightResult)) error {
+	var s *SuccessorMap
+	var results []HeightResult
+	if resumed != nil && resumed.Width == width {
+		var err error
+		s, err = resumed.Restore(t, opts)
+		if err != nil {
+			return fmt.Errorf("couldn't resume from %v: %v", opts.Resume, err)
+		}
+		if opts.Modular != (len(s.Moduli) > 0) {
+			return fmt.Errorf("Modular must be the same as when %v was saved", opts.Resume)
+		}
+		// The primes were chosen for the height requested then.
+		if opts.Modular && len(s.Moduli) < len(moduliFor(t.(Bounded).CountBits(width, maxHeight))) {
+			return fmt.Errorf("%v has too few primes for height %d", opts.Resume, maxHeight)
+		}
+		results = resumed.Results
+		for _, r := range results {
+			if r.Height <= maxHeight {
+				report(r)
+			}
+		}
+	} else {
+		s = NewSuccessorMap(t, width, opts)
+		if opts.Modular {
+			s.UseModuli(moduliFor(t.(Bounded).CountBits(width, maxHeight)))
+		}
+		if opts.DiskDir != "" {
+			if err := s.UseDisk(opts.DiskDir, opts.MemoryBudget); err != nil {
+				return fmt.Errorf("couldn't use %v: %v", opts.DiskDir, err)
+			}
+			defer s.Disk.Remove()
+		}
+	}
+
+	// A checkpoint which cannot be saved stops the run.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var saveErr error
+	err := s.RunContext(ctx, maxHeight, func(r HeightResult) {
+		if r.Height <= len(results) {
+			// Already reported from the checkpoint
+			return
+		}
+		report(r)
+		results = append(results, r)
+		if opts.Checkpoint != "" && saveErr == nil {
+			filename := checkpointName(opts.Checkpoint, width)
+			if err := saveCheckpoint(filename, newCheckpoint(s, results)); err != nil {
+				saveErr = fmt.Errorf("couldn't save checkpoint %v: %v", filename, err)
+				cancel()
+			}
+		}
+	})
+	if saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// RectangleEnumeration reports the count of each case, and of every
+// rectangle on the way to them, with the rectangle enumeration.
+func RectangleEnumeration(cases []Dimensions, opts Options, out Reporter) error {
+	if err := opts.checkRectangle(); err != nil {
+		return err
+	}
+	t := opts.transfer()
+
+	// The number of classes grows with the width, so enumerate
+	// along the longer side.  Every case with the same width can
+	// share one run, up to the tallest height requested.
+	widths := []int{}
+	maxHeight := make(map[int]int)
+	for _, dims := range cases {
+		d := transpose(t, dims)
+		if _, ok := maxHeight[d.Width]; !ok {
+			widths = append(widths, d.Width)
+		}
+		if d.Height > maxHeight[d.Width] {
+			maxHeight[d.Width] = d.Height
+		}
+	}
+
+	var resumed *checkpoint
+	if opts.Resume != "" {
+		c, err := loadCheckpoint(opts.Resume)
+		if err != nil {
+			return fmt.Errorf("couldn't resume from %v: %v", opts.Resume, err)
+		}
+		if _, ok := maxHeight[c.Width]; !ok {
+			return fmt.Errorf("%v is for width %d, which is not being enumerated", opts.Resume, c.Width)
+		}
+		resumed = c
+	}
+
+	for _, width := range widths {
+		method := "rectangle"
+		if opts.Profile {
+			method = "profile"
+		}
+		start := time.Now()
+		byHeight := make(map[int]Result)
+		report := func(r HeightResult) {
+			res := Result{
+				HeightResult: r,
+				Method:       method,
+				Weights:      weightsOf(t),
+				Elapsed:      time.Since(start),
+			}
+			byHeight[r.Height] = res
+			out.Progress(res)
+		}
+
+		err := countWidth(context.Background(), t, width, maxHeight[width], resumed, opts, report)
+		if err != nil {
+			return err
+		}
+
+		for _, dims := range cases {
+			d := transpose(t, dims)
+			if d.Width != width {
+				continue
+			}
+			r := byHeight[d.Height]
+			r.Width, r.Height = dims.Width, dims.Height
+			out.Report(r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package enumerate
+
+import (
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// regionTransfer counts all k-colored grids by their number of regions.
+// Unlike colorTransfer, any region may be closed off, and colors may
+// be re-used, so every row is a possible successor.
+type regionTransfer struct {
+	// Rules.CountRegions must be set, and every color Unrestricted.
+	Rules *equiv.Rules
+
+	// If nonzero, discard grids with more regions than this.
+	MaxRegions int
+
+	// Whether to count the grids by the length of their interface.
+	CountInterface bool
+}
+
+func (t regionTransfer) StartingClasses(width int) map[ClassKey]EdgeClass {
+	byKey := make(map[ClassKey]EdgeClass)
+	for _, e := range t.enumerateChildren(equiv.NewColorRectangle(width, t.Rules)) {
+		byKey[e.Key] = e
+	}
+	return byKey
+}
+
+func (t regionTransfer) Weights() Weights {
+	return Weights{Cells: t.Rules.CountBlack, Interface: t.CountInterface}
+}
+
+func (t regionTransfer) Children(c RowClass) []EdgeClass {
+	return t.enumerateChildren(c.(*equiv.ColorRectangle))
+}
+
+func (t regionTransfer) IsValid(c RowClass) bool {
+	return t.MaxRegions == 0 || t.NumRegions(c) <= t.MaxRegions
+}
+
+func (t regionTransfer) NumRegions(c RowClass) int {
+	cr := c.(*equiv.ColorRectangle)
+	n := cr.Regions
+	for _, sets := range cr.GluedSets() {
+		n += sets
+	}
+	return n
+}
+
+// couldBeValid checks whether the grid might still end up with few
+// enough regions.  Sets on the edge might merge later, but not if they
+// are different colors.
+func (t regionTransfer) couldBeValid(cr *equiv.ColorRectangle) bool {
+	return t.MaxRegions == 0 || cr.Regions+cr.NumEdgeColors() <= t.MaxRegions
+}
+
+func (t regionTransfer) enumerateChildren(cr *equiv.ColorRectangle) []EdgeClass {
+	config := combinations.IndicatorConfig{
+		Size:   cr.Width,
+		Offset: 0,
+	}
+	choices := make([]int, t.Rules.NumColors)
+	for c := range choices {
+		choices[c] = c
+	}
+
+	weights := t.Weights()
+	prev := cr.EdgeColors()
+	byKey := make(map[ClassKey]EdgeClass)
+	add := func(child *equiv.ColorRectangle, row []int) {
+		if !t.couldBeValid(child) {
+			return
+		}
+		key := keyOf(child)
+		n := weights.Row(t.Rules, prev, row)
+		if exist, ok := byKey[key]; ok {
+			byKey[key] = exist.Inc(n)
+		} else {
+			byKey[key] = NewEdgeClass(key, child, n)
+		}
+	}
+
+	gens := make([]combinations.SetGenerator, cr.Width)
+	for pos := range gens {
+		gens[pos] = &combinations.FreeValue{C: config, Index: pos, Choices: choices}
+	}
+
+	ch := make(chan combinations.IndicatorMap)
+	go combinations.Product(gens, ch)
+
+	for row := range ch {
+		add(cr.Expand(row.Values), row.Values)
+	}
+
+	result := make([]EdgeClass, 0, len(byKey))
+	for _, v := range byKey {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,43 @@
+package enumerate
+
+import (
+	"math/big"
+	"time"
+)
+
+// Result is a count from one of the enumerations.
+type Result struct {
+	HeightResult
+
+	// exhaustive, square, rectangle, profile or symmetric
+	Method string
+
+	// Only for exhaustive, the number of grids which were not counted
+	NotValid *big.Int
+
+	// What Distribution counts
+	Weights Weights
+
+	// Only with Options.Symmetric, the number of grids fixed by each
+	// element of Symmetries; the count is then the number of orbits.
+	Fixed []*big.Int
+
+	// The time since the enumeration started
+	Elapsed time.Duration
+}
+
+func (r Result) Dimensions() Dimensions {
+	return Dimensions{r.Width, r.Height}
+}
+
+// Reporter writes the results of an enumeration in some format.
+type Reporter interface {
+	// Progress is called with every rectangle counted on the way to
+	// the sizes requested, by the rectangle enumeration.
+	Progress(r Result)
+
+	// Report is called with the count of each size requested.
+	Report(r Result)
+
+	Close() error
+}
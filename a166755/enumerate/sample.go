@@ -0,0 +1,300 @@
+package enumerate
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// transferHistory keeps the counts of the two-color transfer at every
+// height, so that its classes can be followed back to concrete grids.
+type transferHistory struct {
+	Width  int
+	Height int
+
+	s *SuccessorMap
+
+	// The counts at each height, starting with 1
+	counts []map[ClassKey]EdgeClass
+
+	// The total number of grids
+	Total *big.Int
+
+	// The choices already found for each class and transform
+	cache map[choiceKey][]rowChoice
+}
+
+type choiceKey struct {
+	Key       ClassKey
+	Transform equiv.Transform
+}
+
+func newTransferHistory(width int, height int, opts Options) *transferHistory {
+	th := &transferHistory{
+		Width:  width,
+		Height: height,
+		s:      NewSuccessorMap(twoColorTransfer{}, width, opts),
+		cache:  make(map[choiceKey][]rowChoice),
+	}
+	th.s.KeepClasses()
+	th.s.Run(height, func(r HeightResult) {
+		th.counts = append(th.counts, th.s.CountByClass)
+		th.Total = r.Count
+	})
+	return th
+}
+
+func (th *transferHistory) class(key ClassKey) *equiv.GridRectangle {
+	c, ok := th.s.Classes.Load(key)
+	if !ok {
+		panic("class not found")
+	}
+	return c.(*equiv.GridRectangle)
+}
+
+// rowChoice is a row which may come next in a grid.
+type rowChoice struct {
+	// The row, as it appears in the grid
+	Row []int
+
+	// The class of the grid, once the row is added
+	Key ClassKey
+
+	// How to get from the canonical class back to the grid
+	Transform equiv.Transform
+}
+
+func lessRow(a []int, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// choices lists the rows which may follow a grid of height h in the
+// class with the given key, in order.  The rows of the class are in the
+// frame of its canonical form, which transform takes back to the grid.
+// The first row (h = 0) has no class.  The result should not be
+// modified.
+func (th *transferHistory) choices(h int, key ClassKey, transform equiv.Transform) []rowChoice {
+	if h == 0 {
+		key = ClassKey{}
+	}
+	if ret, ok := th.cache[choiceKey{key, transform}]; ok {
+		return ret
+	}
+
+	ret := make([]rowChoice, 0)
+	if h == 0 {
+		// Only rows with at most three runs of color are counted; see
+		// startingClasses.
+		seen := make(map[string]bool)
+		for c := 0; c <= 1; c++ {
+			for left := 0; left <= th.Width; left++ {
+				for right := left; right <= th.Width; right++ {
+					row := make([]int, th.Width)
+					for x := range row {
+						if x < left || x >= right {
+							row[x] = c
+						} else {
+							row[x] = 1 - c
+						}
+					}
+					if seen[fmt.Sprint(row)] {
+						continue
+					}
+					seen[fmt.Sprint(row)] = true
+
+					gr := equiv.RectangleClassForGrid(th.Width, 1, [][]int{row})
+					t := gr.Canonicalize()
+					ret = append(ret, rowChoice{row, keyOf(gr), t})
+				}
+			}
+		}
+	} else {
+		prev := th.class(key)
+		rectangleRows(prev, func(row []int) {
+			child, t := prev.ExpandTransform(row)
+			ret = append(ret, rowChoice{transform.Apply(row), keyOf(child), transform.Then(t)})
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return lessRow(ret[i].Row, ret[j].Row)
+	})
+	th.cache[choiceKey{key, transform}] = ret
+	return ret
+}
+
+// sampler draws grids with exactly two regions uniformly at random.
+//
+// The transfer counts the grids in each class at every height.  The
+// sampler picks a valid class at the last height in proportion to its
+// count, then each earlier class in proportion to the number of grids
+// that reach it and continue to the class already picked.  Finally it
+// picks the rows themselves, top to bottom, keeping track of how each
+// canonical class was flipped or had its colors swapped.
+type sampler struct {
+	*transferHistory
+	rnd *rand.Rand
+}
+
+func newSampler(width int, height int, rnd *rand.Rand, opts Options) *sampler {
+	return &sampler{newTransferHistory(width, height, opts), rnd}
+}
+
+// choose returns an index with probability proportional to its weight.
+func (sm *sampler) choose(weights []*big.Int) int {
+	total := big.NewInt(0)
+	for _, w := range weights {
+		total.Add(total, w)
+	}
+	if total.Sign() == 0 {
+		panic("nothing to choose from")
+	}
+	r := new(big.Int).Rand(sm.rnd, total)
+	for i, w := range weights {
+		if r.Cmp(w) < 0 {
+			return i
+		}
+		r.Sub(r, w)
+	}
+	panic("choice out of range")
+}
+
+// sortedKeys lists the classes at a height in a fixed order, so that
+// the same seed always gives the same grids.
+func sortedKeys(counts map[ClassKey]EdgeClass) []ClassKey {
+	keys := make([]ClassKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Less(keys[j])
+	})
+	return keys
+}
+
+// classPath picks the key of the class at each height, indexed
+// from 0 for the first row.
+func (sm *sampler) classPath() []ClassKey {
+	path := make([]ClassKey, sm.Height)
+
+	last := sm.counts[sm.Height-1]
+	keys := make([]ClassKey, 0)
+	weights := make([]*big.Int, 0)
+	for _, k := range sortedKeys(last) {
+		if _, valid := sm.s.ValidClasses.Load(k); valid {
+			keys = append(keys, k)
+			weights = append(weights, last[k].Count)
+		}
+	}
+	path[sm.Height-1] = keys[sm.choose(weights)]
+
+	for h := sm.Height - 2; h >= 0; h-- {
+		keys = keys[:0]
+		weights = weights[:0]
+		for _, k := range sortedKeys(sm.counts[h]) {
+			successors, _ := sm.s.SuccessorCounts.Load(k)
+			for _, e := range successors.([]EdgeClass) {
+				if e.Key == path[h+1] {
+					keys = append(keys, k)
+					weights = append(weights,
+						new(big.Int).Mul(sm.counts[h][k].Count, e.Count))
+				}
+			}
+		}
+		path[h] = keys[sm.choose(weights)]
+	}
+	return path
+}
+
+// Sample returns a random grid, indexed by row and then column.
+func (sm *sampler) Sample() [][]int {
+	path := sm.classPath()
+	grid := make([][]int, sm.Height)
+
+	var transform equiv.Transform
+	for h := 0; h < sm.Height; h++ {
+		var prev ClassKey
+		if h > 0 {
+			prev = path[h-1]
+		}
+		// Every row which reaches the class picked is equally likely.
+		rows := make([]rowChoice, 0)
+		for _, ch := range sm.choices(h, prev, transform) {
+			if ch.Key == path[h] {
+				rows = append(rows, ch)
+			}
+		}
+		if h == 0 && int64(len(rows)) != sm.counts[0][path[0]].Count.Int64() {
+			panic("first rows do not match the class count")
+		}
+		ch := rows[sm.rnd.Intn(len(rows))]
+		grid[h] = ch.Row
+		transform = ch.Transform
+	}
+	return grid
+}
+
+// showGrid draws white cells as . and black cells as X.
+func showGrid(grid [][]int) string {
+	var buf strings.Builder
+	for _, row := range grid {
+		for _, c := range row {
+			if c == 1 {
+				buf.WriteString("X")
+			} else {
+				buf.WriteString(".")
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func transposeGrid(grid [][]int) [][]int {
+	ret := make([][]int, len(grid[0]))
+	for x := range ret {
+		ret[x] = make([]int, len(grid))
+		for y := range grid {
+			ret[x][y] = grid[y][x]
+		}
+	}
+	return ret
+}
+
+// SampleEnumeration writes numSamples grids with two regions, chosen
+// uniformly at random, for each case to out.
+func SampleEnumeration(out io.Writer, cases []Dimensions, numSamples int, seed int64, opts Options) error {
+	if err := opts.checkTwoColorMethod("SampleEnumeration"); err != nil {
+		return err
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	for _, dims := range cases {
+		d := dims.Transposed()
+		sm := newSampler(d.Width, d.Height, rnd, opts)
+		if dims.IsSquare() {
+			fmt.Fprintf(out, "**** N=%v | grids=%v | seed=%v\n\n", dims.Width, sm.Total, seed)
+		} else {
+			fmt.Fprintf(out, "**** %v | grids=%v | seed=%v\n\n", dims, sm.Total, seed)
+		}
+		if sm.Total.Sign() == 0 {
+			continue
+		}
+		for i := 0; i < numSamples; i++ {
+			grid := sm.Sample()
+			if d != dims {
+				grid = transposeGrid(grid)
+			}
+			fmt.Fprintf(out, "%v\n", showGrid(grid))
+		}
+	}
+	return nil
+}
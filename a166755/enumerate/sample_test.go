@@ -0,0 +1,49 @@
+package enumerate
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSampler_Seeded(t *testing.T) {
+	first := newSampler(4, 5, rand.New(rand.NewSource(42)), Options{})
+	second := newSampler(4, 5, rand.New(rand.NewSource(42)), Options{})
+	for i := 0; i < 20; i++ {
+		a := first.Sample()
+		b := second.Sample()
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("sample %d differs with the same seed:\n%v\n%v", i, showGrid(a), showGrid(b))
+		}
+	}
+}
+
+func TestSampler_Uniform(t *testing.T) {
+	const perGrid = 200
+	for _, d := range []Dimensions{{2, 3}, {3, 3}} {
+		valid, _ := twoRegionGrids(d.Width, d.Height)
+		frequency := make(map[string]int)
+		for _, grid := range valid {
+			frequency[fmt.Sprint(grid)] = 0
+		}
+
+		sm := newSampler(d.Width, d.Height, rand.New(rand.NewSource(1)), Options{})
+		for i := 0; i < perGrid*len(valid); i++ {
+			grid := sm.Sample()
+			key := fmt.Sprint(grid)
+			if _, ok := frequency[key]; !ok {
+				t.Fatalf("%v: sampled a grid without two regions:\n%v", d, showGrid(grid))
+			}
+			frequency[key] += 1
+		}
+
+		// Each count is binomial, with a standard deviation of about
+		// 14, so this only fails if the sampler is biased.
+		for _, grid := range valid {
+			if n := frequency[fmt.Sprint(grid)]; n < perGrid/2 || n > perGrid*3/2 {
+				t.Errorf("%v: sampled %d times, expected about %d:\n%v", d, n, perGrid, showGrid(grid))
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package enumerate
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/combinations"
+	"github.com/mgritter/oeis/a166755/equiv"
+)
+
+// Burnside's lemma says the number of grids which are different up to
+// rotation, reflection, and swapping the colors is the average number
+// of grids left unchanged by each of those 16 symmetries.
+
+type symmetryKind int
+
+const (
+	identityKind symmetryKind = iota
+	quarterTurnKind
+	halfTurnKind
+	mirrorKind
+	diagonalKind
+)
+
+// Symmetry is one element of D4 x C2, acting on an n x n grid.
+type Symmetry struct {
+	Name string
+	Kind symmetryKind
+
+	// Move returns where the cell at (x, y) goes, 0-based.
+	Move func(n, x, y int) (int, int)
+
+	// If true, the colors are also swapped.
+	Swap bool
+}
+
+// Fixes checks whether the grid, in row-major order, is unchanged.
+func (s Symmetry) Fixes(n int, colors []int) bool {
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			i, j := s.Move(n, x, y)
+			c := colors[y*n+x]
+			if s.Swap {
+				c = 1 - c
+			}
+			if colors[j*n+i] != c {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s Symmetry) String() string {
+	if s.Swap {
+		return s.Name + "+swap"
+	}
+	return s.Name
+}
+
+var dihedralGroup = []Symmetry{
+	{"identity", identityKind, func(n, x, y int) (int, int) { return x, y }, false},
+	{"rotate90", quarterTurnKind, func(n, x, y int) (int, int) { return n - 1 - y, x }, false},
+	{"rotate180", halfTurnKind, func(n, x, y int) (int, int) { return n - 1 - x, n - 1 - y }, false},
+	{"rotate270", quarterTurnKind, func(n, x, y int) (int, int) { return y, n - 1 - x }, false},
+	{"flipX", mirrorKind, func(n, x, y int) (int, int) { return n - 1 - x, y }, false},
+	{"flipY", mirrorKind, func(n, x, y int) (int, int) { return x, n - 1 - y }, false},
+	{"diagonal", diagonalKind, func(n, x, y int) (int, int) { return y, x }, false},
+	{"antidiagonal", diagonalKind, func(n, x, y int) (int, int) { return n - 1 - y, n - 1 - x }, false},
+}
+
+// Symmetries lists the dihedral group, then the same elements
+// combined with a color swap.
+var Symmetries = func() []Symmetry {
+	ret := make([]Symmetry, 0, 2*len(dihedralGroup))
+	ret = append(ret, dihedralGroup...)
+	for _, s := range dihedralGroup {
+		s.Swap = true
+		ret = append(ret, s)
+	}
+	return ret
+}()
+
+// palindrome allows rows which read the same in both directions.
+func palindrome(row []int) bool {
+	for i := range row {
+		if row[i] != row[len(row)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// antiPalindrome allows rows which read the same in both directions
+// once the colors are swapped.
+func antiPalindrome(row []int) bool {
+	for i := range row {
+		if row[i] == row[len(row)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// squareSymmetries holds the square classes shared by every size.
+type squareSymmetries struct {
+	opts Options
+
+	// All classes of each size, indexed by size.
+	classes []*EquivalenceClasses
+
+	// Classes of each size with a diagonal line of symmetry.
+	diagonal []*EquivalenceClasses
+}
+
+func newSquareSymmetries(opts Options) *squareSymmetries {
+	return &squareSymmetries{
+		opts:     opts,
+		classes:  []*EquivalenceClasses{nil, InitEquivalenceClasses()},
+		diagonal: []*EquivalenceClasses{nil, InitEquivalenceClasses()},
+	}
+}
+
+func (q *squareSymmetries) Classes(size int) *EquivalenceClasses {
+	for len(q.classes) <= size {
+		q.classes = append(q.classes, nextEquivalenceClasses(q.classes[len(q.classes)-1], nil, q.opts))
+	}
+	return q.classes[size]
+}
+
+// Diagonal only uses borders that are symmetric under p -> -p, which
+// is the mirror along the main diagonal.
+func (q *squareSymmetries) Diagonal(size int) *EquivalenceClasses {
+	for len(q.diagonal) <= size {
+		q.diagonal = append(q.diagonal, nextEquivalenceClasses(q.diagonal[len(q.diagonal)-1], palindrome, q.opts))
+	}
+	return q.diagonal[size]
+}
+
+// QuarterTurnCount counts the n x n grids fixed by a quarter turn,
+// which are four copies of their top left corner.
+func (q *squareSymmetries) QuarterTurnCount(n int, swap bool) *big.Int {
+	total := big.NewInt(0)
+	ec := q.Classes((n + 1) / 2)
+	for key, gb := range ec.Classes {
+		if gb.QuarterTurnValid(swap, n%2 == 1) {
+			total.Add(total, new(big.Int).SetUint64(ec.CountByClass[key]))
+		}
+	}
+	return total
+}
+
+// halfTurnCounts returns the number of n x n grids fixed by a half
+// turn (without and with swapping the colors), which are two copies
+// of their top half.
+func halfTurnCounts(s *SuccessorMap, n int) (*big.Int, *big.Int) {
+	plain := big.NewInt(0)
+	swapped := big.NewInt(0)
+	for k, v := range s.CountByClass {
+		c, _ := s.Classes.Load(k)
+		gr := c.(*equiv.GridRectangle)
+		if gr.HalfTurnValid(false, n%2 == 1) {
+			plain.Add(plain, v.Count)
+		}
+		if gr.HalfTurnValid(true, n%2 == 1) {
+			swapped.Add(swapped, v.Count)
+		}
+	}
+	return plain, swapped
+}
+
+// transferCount counts the n x n grids using only the rows allowed.
+func transferCount(n int, allowed func(row []int) bool, opts Options) *big.Int {
+	s := NewSuccessorMap(twoColorTransfer{Rows: allowed}, n, opts)
+	var count *big.Int
+	s.Run(n, func(r HeightResult) {
+		count = r.Count
+	})
+	return count
+}
+
+// fixedCounts counts the n x n grids fixed by each element
+// of Symmetries.
+func fixedCounts(n int, q *squareSymmetries) []*big.Int {
+	var identity, halfTurn, halfTurnSwap *big.Int
+	s := NewSuccessorMap(twoColorTransfer{}, n, q.opts)
+	s.KeepClasses()
+	s.Run(n, func(r HeightResult) {
+		if r.Height == (n+1)/2 {
+			halfTurn, halfTurnSwap = halfTurnCounts(s, n)
+		}
+		identity = r.Count
+	})
+	mirror := transferCount(n, palindrome, q.opts)
+	mirrorSwap := transferCount(n, antiPalindrome, q.opts)
+	diagonal := new(big.Int).SetUint64(q.Diagonal(n).CountValid)
+	quarterTurn := q.QuarterTurnCount(n, false)
+	quarterTurnSwap := q.QuarterTurnCount(n, true)
+
+	fixed := make([]*big.Int, len(Symmetries))
+	for i, sym := range Symmetries {
+		switch {
+		case sym.Kind == identityKind && !sym.Swap:
+			fixed[i] = identity
+		case sym.Kind == halfTurnKind && !sym.Swap:
+			fixed[i] = halfTurn
+		case sym.Kind == halfTurnKind:
+			fixed[i] = halfTurnSwap
+		case sym.Kind == quarterTurnKind && !sym.Swap:
+			fixed[i] = quarterTurn
+		case sym.Kind == quarterTurnKind:
+			fixed[i] = quarterTurnSwap
+		case sym.Kind == mirrorKind && !sym.Swap:
+			fixed[i] = mirror
+		case sym.Kind == mirrorKind:
+			fixed[i] = mirrorSwap
+		case sym.Kind == diagonalKind && !sym.Swap:
+			fixed[i] = diagonal
+		default:
+			// Every cell on the line would have to change color.
+			fixed[i] = big.NewInt(0)
+		}
+	}
+	return fixed
+}
+
+// symmetricResult is the number of n x n grids fixed by each element
+// of Symmetries, with the number of orbits as its count.
+func symmetricResult(n int, fixed []*big.Int, method string) Result {
+	total := big.NewInt(0)
+	for _, f := range fixed {
+		total.Add(total, f)
+	}
+	orbits := new(big.Int).Div(total, big.NewInt(int64(len(fixed))))
+	return Result{
+		HeightResult: HeightResult{Width: n, Height: n, Count: orbits},
+		Method:       method,
+		Fixed:        fixed,
+	}
+}
+
+// SymmetricEnumeration reports the number of n x n grids fixed by each
+// symmetry, for each of the square cases.
+func SymmetricEnumeration(cases []Dimensions, opts Options, out Reporter) error {
+	if err := opts.checkOther("SymmetricEnumeration", true); err != nil {
+		return err
+	}
+	if err := opts.checkTwoColors("SymmetricEnumeration"); err != nil {
+		return err
+	}
+	if err := checkSquares("SymmetricEnumeration", cases); err != nil {
+		return err
+	}
+	q := newSquareSymmetries(opts)
+	for _, d := range cases {
+		start := time.Now()
+		r := symmetricResult(d.Width, fixedCounts(d.Width, q), "symmetric")
+		r.Elapsed = time.Since(start)
+		out.Report(r)
+	}
+	return nil
+}
+
+// countFixed adds up which symmetries fix the grid.
+func countFixed(n int, grid combinations.IndicatorMap, total *Count) {
+	if total.Fixed == nil {
+		total.Fixed = make([]int, len(Symmetries))
+	}
+	for i, sym := range Symmetries {
+		if sym.Fixes(n, grid.Values) {
+			total.Fixed[i] += 1
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package enumerate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// image returns where the symmetry takes the grid, in row-major order.
+func (s Symmetry) image(n int, colors []int) []int {
+	ret := make([]int, len(colors))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			i, j := s.Move(n, x, y)
+			c := colors[y*n+x]
+			if s.Swap {
+				c = 1 - c
+			}
+			ret[j*n+i] = c
+		}
+	}
+	return ret
+}
+
+// countOrbits counts the n x n grids with two regions which are
+// different up to the elements of Symmetries, by keeping the least
+// image of each.
+func countOrbits(n int) int {
+	valid, _ := twoRegionGrids(n, n)
+	orbits := make(map[string]bool)
+	for _, grid := range valid {
+		colors := flattenGrid(grid)
+		least := colors
+		for _, sym := range Symmetries {
+			if image := sym.image(n, colors); lessRow(image, least) {
+				least = image
+			}
+		}
+		orbits[fmt.Sprint(least)] = true
+	}
+	return len(orbits)
+}
+
+func TestFixedCounts_MatchExhaustive(t *testing.T) {
+	q := newSquareSymmetries(Options{})
+	for n := 2; n <= 4; n++ {
+		fixed := fixedCounts(n, q)
+		total, err := exhaustiveCount(context.Background(), Dimensions{n, n}, Options{Symmetric: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, sym := range Symmetries {
+			if fixed[i].Int64() != int64(total.Fixed[i]) {
+				t.Errorf("N=%d: %v fixes %v grids, expected %d", n, sym, fixed[i], total.Fixed[i])
+			}
+		}
+
+		orbits := symmetricResult(n, fixed, "symmetric").Count
+		if expected := countOrbits(n); orbits.Int64() != int64(expected) {
+			t.Errorf("N=%d: %v orbits, expected %d", n, orbits, expected)
+		}
+	}
+}
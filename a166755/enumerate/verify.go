@@ -0,0 +1,120 @@
+package enumerate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Every method of counting the n x n grids with two regions should give
+// the same answer.  VerifyEnumeration runs each of them, and reports
+// any which disagree with the majority.
+
+var verifyMethods = []string{"exhaustive", "square", "rectangle", "profile"}
+
+// verifyCounts returns the counts from each method, indexed by method
+// and then n, from 2 to max.
+func verifyCounts(max int, opts Options) ([][]*big.Int, error) {
+	ctx := context.Background()
+	profile := opts
+	profile.Profile = true
+
+	counts := make([][]*big.Int, len(verifyMethods))
+	for m := range counts {
+		counts[m] = make([]*big.Int, max+1)
+	}
+	for m, method := range verifyMethods {
+		for n := 2; n <= max; n++ {
+			var err error
+			switch method {
+			case "exhaustive":
+				counts[m][n], _, err = CountExhaustive(ctx, n, n, opts)
+			case "square":
+				counts[m][n], _, err = CountSquare(ctx, n, opts)
+			case "rectangle":
+				counts[m][n], _, err = CountRectangle(ctx, n, n, opts)
+			case "profile":
+				counts[m][n], _, err = CountRectangle(ctx, n, n, profile)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", method, err)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// majority returns the count given by more methods than any other, or
+// nil if there is a tie.
+func majority(values []*big.Int) *big.Int {
+	var best *big.Int
+	bestVotes := 0
+	tied := false
+	for _, v := range values {
+		votes := 0
+		for _, w := range values {
+			if v.Cmp(w) == 0 {
+				votes += 1
+			}
+		}
+		if votes > bestVotes {
+			best, bestVotes, tied = v, votes, false
+		} else if votes == bestVotes && v.Cmp(best) != 0 {
+			tied = true
+		}
+	}
+	if tied {
+		return nil
+	}
+	return best
+}
+
+// VerifyEnumeration compares the methods for n from 2 to max, writing
+// the counts to out, and returns false if any of them disagree.
+func VerifyEnumeration(out io.Writer, max int, opts Options) (bool, error) {
+	if max < 2 {
+		return false, fmt.Errorf("VerifyEnumeration needs a size of at least 2, not %d", max)
+	}
+	if err := opts.checkTwoColorMethod("VerifyEnumeration"); err != nil {
+		return false, err
+	}
+	counts, err := verifyCounts(max, opts)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(out, "\n**** n | %v\n", strings.Join(verifyMethods, " | "))
+	differs := make([][]int, len(verifyMethods))
+	for n := 2; n <= max; n++ {
+		values := make([]*big.Int, len(verifyMethods))
+		for m := range verifyMethods {
+			values[m] = counts[m][n]
+		}
+		expected := majority(values)
+		line := make([]string, len(values))
+		for m, v := range values {
+			line[m] = v.String()
+			if expected == nil || v.Cmp(expected) != 0 {
+				differs[m] = append(differs[m], n)
+				line[m] += " MISMATCH"
+			}
+		}
+		fmt.Fprintf(out, "%d | %v\n", n, strings.Join(line, " | "))
+	}
+	fmt.Fprintf(out, "\n")
+
+	ok := true
+	for m, method := range verifyMethods {
+		if len(differs[m]) == 0 {
+			fmt.Fprintf(out, "  %v | ok\n", method)
+			continue
+		}
+		ok = false
+		for _, n := range differs[m] {
+			fmt.Fprintf(out, "  %v | n=%d | grids=%v\n", method, n, counts[m][n])
+		}
+	}
+	return ok, nil
+}
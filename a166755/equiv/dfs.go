@@ -136,20 +136,21 @@ func EdgeClassForGrid(n int, squares [][]int) *GridBoundary {
 }
 
 func RectangleClassForGrid(width int, height int, squares [][]int) *GridRectangle {
-	// To re-use the DFS code that assumes a square grid we will
-	// pad the squares out.
-	colors := make([]int, width*width)
+	return RectangleClassForBoard(Board{Width: width, Height: height}, squares)
+}
+
+// RectangleClassForBoard is like RectangleClassForGrid, but uses the
+// adjacency of the board, which must be the same for both colors.
+func RectangleClassForBoard(board Board, squares [][]int) *GridRectangle {
+	width := board.Width
+	height := board.Height
+	colors := make([]int, width*height)
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
 			colors[y*width+x] = squares[y][x]
 		}
 	}
-	for y := height; y < width; y++ {
-		for x := 0; x < width; x++ {
-			colors[y*width+x] = -1
-		}
-	}
-	visited := make([]bool, width*width)
+	visited := make([]bool, width*height)
 
 	ret := &GridRectangle{
 		Width:      width,
@@ -157,6 +158,7 @@ func RectangleClassForGrid(width int, height int, squares [][]int) *GridRectangl
 		SolidColor: false,
 		White:      EdgePartition{Sets: make([][]int, 0)},
 		Black:      EdgePartition{Sets: make([][]int, 0)},
+		Adjacency:  board.AdjacencyOf(0),
 	}
 
 	numComponents := 0
@@ -184,14 +186,14 @@ func RectangleClassForGrid(width int, height int, squares [][]int) *GridRectangl
 
 	for x := 1; x <= width; x++ {
 		addComponent(
-			ConnectedComponentDFS(width, colors, Coord{x, height}, visited),
+			board.ConnectedComponent(colors, Coord{x, height}, visited),
 			squares[height-1][x-1],
 		)
 	}
 
 	if numComponents == 1 {
 		ret.SolidColor = true
-		for _, v := range colors[:width*height] {
+		for _, v := range colors {
 			if v != colors[0] {
 				ret.SolidColor = false
 				break
@@ -201,3 +203,193 @@ func RectangleClassForGrid(width int, height int, squares [][]int) *GridRectangl
 
 	return ret
 }
+
+// Board is a rectangular grid for the DFS, possibly wrapping around
+// horizontally (a cylinder) or in both directions (a torus.)
+type Board struct {
+	Width  int
+	Height int
+	WrapX  bool
+	WrapY  bool
+
+	// The adjacency of each color; if nil, every color uses Four.
+	Adjacency []Adjacency
+
+	// For a Triangular lattice, Width counts triangles, not rhombi,
+	// and for a Cubic one, every cube in a layer.
+	Lattice Lattice
+}
+
+func (b Board) Index(c Coord) int {
+	return (c.Y-1)*b.Width + (c.X - 1)
+}
+
+// AdjacencyOf returns the adjacency used by one color.
+func (b Board) AdjacencyOf(color int) Adjacency {
+	if b.Adjacency == nil {
+		return Four
+	}
+	return b.Adjacency[color]
+}
+
+// neighbor returns the cell offset by (dx, dy), if it is on the board.
+func (b Board) neighbor(curr Coord, dx int, dy int) (Coord, bool) {
+	x := curr.X + dx
+	y := curr.Y + dy
+	if x < 1 || x > b.Width {
+		if !b.WrapX || b.Width == 1 {
+			return Coord{}, false
+		}
+		x = (x+b.Width-1)%b.Width + 1
+	}
+	if y < 1 || y > b.Height {
+		if !b.WrapY || b.Height == 1 {
+			return Coord{}, false
+		}
+		y = (y+b.Height-1)%b.Height + 1
+	}
+	return Coord{x, y}, true
+}
+
+func (b Board) Neighbors(curr Coord, adjacency Adjacency) []Coord {
+	var offsets [][2]int
+	switch b.Lattice {
+	case Square:
+		offsets = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+		if adjacency == Eight {
+			offsets = append(offsets, [2]int{-1, -1}, [2]int{1, -1}, [2]int{-1, 1}, [2]int{1, 1})
+		}
+	case Hexagonal:
+		offsets = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {1, -1}, {-1, 1}}
+	case Cubic:
+		// X numbers the cubes in a layer, so moving across the layer
+		// must not wrap around to the next line of cubes.
+		side := layerSide(b.Width)
+		x := (curr.X - 1) % side
+		offsets = [][2]int{{0, -1}, {0, 1}}
+		if x > 0 {
+			offsets = append(offsets, [2]int{-1, 0})
+		}
+		if x < side-1 {
+			offsets = append(offsets, [2]int{1, 0})
+		}
+		offsets = append(offsets, [2]int{-side, 0}, [2]int{side, 0})
+	case Triangular:
+		// Upward triangles are at odd X, since it starts from 1.
+		if curr.X%2 == 1 {
+			offsets = [][2]int{{-1, 0}, {1, 0}, {1, -1}}
+		} else {
+			offsets = [][2]int{{-1, 0}, {1, 0}, {-1, 1}}
+		}
+	}
+	nn := make([]Coord, 0, len(offsets))
+	for _, d := range offsets {
+		if n, ok := b.neighbor(curr, d[0], d[1]); ok {
+			nn = append(nn, n)
+		}
+	}
+	return nn
+}
+
+// InterfaceLength counts the sides shared by cells of different colors.
+func (b Board) InterfaceLength(colors []int) int {
+	n := 0
+	for y := 1; y <= b.Height; y++ {
+		for x := 1; x <= b.Width; x++ {
+			curr := Coord{x, y}
+			for _, nn := range b.Neighbors(curr, Four) {
+				if colors[b.Index(nn)] != colors[b.Index(curr)] {
+					n += 1
+				}
+			}
+		}
+	}
+	// Each side was seen from both cells.
+	return n / 2
+}
+
+// ConnectedComponent is like ConnectedComponentDFS, for a Board.
+func (b Board) ConnectedComponent(colors []int, start Coord, visited []bool) []Coord {
+	component := make([]Coord, 0)
+
+	var dfs func(Coord)
+	dfs = func(curr Coord) {
+		if visited[b.Index(curr)] {
+			return
+		}
+		component = append(component, curr)
+		visited[b.Index(curr)] = true
+
+		color := colors[b.Index(curr)]
+		for _, n := range b.Neighbors(curr, b.AdjacencyOf(color)) {
+			if colors[b.Index(n)] == color {
+				dfs(n)
+			}
+		}
+	}
+
+	dfs(start)
+	return component
+}
+
+func ColorClassForGrid(width int, height int, rules *Rules, squares [][]int) *ColorRectangle {
+	// The first row of a torus is not glued to the last one yet.
+	board := NewBoard(width, height, rules)
+	board.WrapY = false
+	boundary := rules.Boundary
+	ret := NewColorRectangle(width, rules)
+	ret.Height = height
+	// The number of cells in each row
+	width = board.Width
+
+	colors := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			colors[y*width+x] = squares[y][x]
+		}
+	}
+	visited := make([]bool, width*height)
+
+	addComponent := func(component []Coord) {
+		if len(component) == 0 {
+			return
+		}
+		edges := make([]int, 0)
+		for _, c := range component {
+			if c.Y == height {
+				edges = append(edges, c.X-1)
+			}
+			if boundary == Torus && c.Y == 1 {
+				edges = append(edges, width+c.X-1)
+			}
+		}
+		color := colors[board.Index(component[0])]
+		ret.Colors[color].Sets = append(ret.Colors[color].Sets, edges)
+	}
+
+	for x := 1; x <= width; x++ {
+		addComponent(board.ConnectedComponent(colors, Coord{x, height}, visited))
+		if boundary == Torus {
+			addComponent(board.ConnectedComponent(colors, Coord{x, 1}, visited))
+		}
+	}
+
+	// Any remaining components do not touch the edge.
+	for y := 1; y <= height; y++ {
+		for x := 1; x <= width; x++ {
+			component := board.ConnectedComponent(colors, Coord{x, y}, visited)
+			if len(component) == 0 {
+				continue
+			}
+			color := colors[board.Index(component[0])]
+			if rules.countsRegion(color) {
+				ret.Regions += 1
+			}
+			if rules.ConstraintOf(color) == Connected && len(ret.Colors[color].Sets) == 0 {
+				ret.Closed[color] = true
+			}
+		}
+	}
+
+	return ret
+}
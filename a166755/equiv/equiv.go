@@ -210,6 +210,57 @@ func (g *GridBoundary) Key() string {
 	return buf.String()
 }
 
+// MaxPackedCells is the longest edge with a PackedKey.
+const MaxPackedCells = 32
+
+// PackedKey encodes a canonical edge in a fixed-size array, which can
+// be hashed and compared without allocating.  Byte i describes the
+// i'th cell of the edge: its color in the low bit, and above that the
+// label of its set, numbered from 1 in order of first appearance.
+// Bytes after the end of the edge are 0, and the last byte is 1 if
+// the grid is a solid color.
+type PackedKey [MaxPackedCells + 1]byte
+
+// packEdge fills a PackedKey for an edge of n cells, with the cell at
+// position pos stored in byte pos+offset.  It returns false if the
+// edge is too long.
+func packEdge(n int, offset int, solid bool, white *EdgePartition, black *EdgePartition) (PackedKey, bool) {
+	var key PackedKey
+	if n > MaxPackedCells {
+		return key, false
+	}
+
+	// First record the color and the index of each cell's set (plus
+	// one, to tell it from an empty cell), then renumber the sets.
+	var setOf [MaxPackedCells]byte
+	for c, part := range []*EdgePartition{white, black} {
+		for i, s := range part.Sets {
+			for _, pos := range s {
+				setOf[pos+offset] = byte((i+1)<<1 | c)
+			}
+		}
+	}
+	var labels [2 * (MaxPackedCells + 1)]byte
+	next := byte(1)
+	for i := 0; i < n; i++ {
+		if labels[setOf[i]] == 0 {
+			labels[setOf[i]] = next
+			next += 1
+		}
+		key[i] = labels[setOf[i]]<<1 | setOf[i]&1
+	}
+	if solid {
+		key[MaxPackedCells] = 1
+	}
+	return key, true
+}
+
+// PackedKey returns the same information as Key, if the edge is
+// at most MaxPackedCells long.
+func (g *GridBoundary) PackedKey() (PackedKey, bool) {
+	return packEdge(2*g.Size-1, g.Size-1, g.SolidColor, &g.White, &g.Black)
+}
+
 // Plot shows a graphical representation of the congruence class.
 func (g *GridBoundary) Plot() string {
 	whiteLetters := "abcdefghijklmnopqrstuvwxyz"
@@ -0,0 +1,377 @@
+package equiv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mgritter/oeis/a166755/unionfind"
+)
+
+// Equivalence classes of k-colored rectangles based on their lower
+// edge, generalizing GridRectangle.
+//
+//  x   x   x   x
+//  0   1   2   3
+//
+// There is one EdgePartition per color.  The canonical label is
+// obtained by:
+//   * sort the positions within each edge set
+//   * sort the edge sets within each color by their minimum element
+//   * renumber the colors in order of their first appearance along
+//     the edge; colors not on the edge come last
+//   * take the minimum over flipping along the vertical line x = (n-1)/2
+//
+// We also count the regions which no longer touch the edge: those of
+// the Connected colors, or of every color if Rules.CountRegions is set.
+//
+// On a cylinder, column 0 is adjacent to column n-1, so we also take the
+// minimum over rotations of the edge.  On a torus, the last row will be
+// glued back to the first, so the first row is part of the edge too; its
+// cells are numbered n through 2n-1:
+//
+//  n  n+1 n+2 n+3
+//  x   x   x   x
+//  0   1   2   3
+//
+// and regions which touch it are never finished until the end.
+//
+// When each color must form a single region, the colors which are not
+// on the edge are either unused so far, or "closed": their region is one
+// of those which no longer touch the edge, so they may not be used again.
+//
+// On the hexagonal and triangular lattices, the edge may not be
+// flipped, and on a triangular cylinder it may only be rotated by
+// an even number of positions, keeping the triangles pointing the
+// same way.  On the cubic lattice, the edge is a square layer, which
+// may be rotated or reflected in any of 8 ways.
+//
+// Only interchangeable colors are renumbered; if black and white use
+// different adjacency, they keep their own numbers.  Within each group
+// of interchangeable colors, the closed colors come after the edge
+// colors, and the unused colors after that.
+
+type ColorRectangle struct {
+	Width   int
+	Height  int
+	Rules   *Rules
+	Regions int
+	Closed  []bool
+	Colors  []EdgePartition
+}
+
+// NewColorRectangle returns the class of the empty (height 0) grid.
+// On a triangular lattice, its Width counts triangles, not rhombi.
+func NewColorRectangle(width int, rules *Rules) *ColorRectangle {
+	ret := &ColorRectangle{
+		Width:   rules.Lattice.RowCells(width),
+		Height:  0,
+		Rules:   rules,
+		Regions: 0,
+		Closed:  make([]bool, rules.NumColors),
+		Colors:  make([]EdgePartition, rules.NumColors),
+	}
+	for i := range ret.Colors {
+		ret.Colors[i].Sets = make([]EdgeSet, 0)
+	}
+	return ret
+}
+
+// NumEdgeColors returns the number of colors present on the edge.
+func (g *ColorRectangle) NumEdgeColors() int {
+	n := 0
+	for i := range g.Colors {
+		if len(g.Colors[i].Sets) > 0 {
+			n += 1
+		}
+	}
+	return n
+}
+
+// NumEdgeSets returns the number of regions which touch the edge.
+func (g *ColorRectangle) NumEdgeSets() int {
+	n := 0
+	for i := range g.Colors {
+		n += len(g.Colors[i].Sets)
+	}
+	return n
+}
+
+// EdgeColors returns the color of each cell in the last row, or nil
+// if the grid is empty.
+func (g *ColorRectangle) EdgeColors() []int {
+	if g.Height == 0 {
+		return nil
+	}
+	ret := make([]int, g.Width)
+	for c := range g.Colors {
+		for _, s := range g.Colors[c].Sets {
+			for _, pos := range s {
+				if pos < g.Width {
+					ret[pos] = c
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// UnusedColors returns the colors which have not yet appeared in the grid,
+// assuming each color forms a single region.
+func (g *ColorRectangle) UnusedColors() []int {
+	ret := make([]int, 0)
+	for c := range g.Colors {
+		if len(g.Colors[c].Sets) == 0 && !g.Closed[c] {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// sortColors renumbers each group of interchangeable colors: first
+// in order of their appearance on the edge, then the closed colors.
+func sortColors(groups [][]int, colors []EdgePartition, closed []bool) {
+	before := func(a int, b int) bool {
+		if len(colors[b].Sets) == 0 {
+			return len(colors[a].Sets) > 0 || (closed[a] && !closed[b])
+		}
+		if len(colors[a].Sets) == 0 {
+			return false
+		}
+		return colors[a].Sets[0][0] < colors[b].Sets[0][0]
+	}
+	for _, group := range groups {
+		order := make([]int, len(group))
+		copy(order, group)
+		sort.SliceStable(order, func(i, j int) bool {
+			return before(order[i], order[j])
+		})
+		sortedColors := make([]EdgePartition, len(group))
+		sortedClosed := make([]bool, len(group))
+		for i, c := range order {
+			sortedColors[i] = colors[c]
+			sortedClosed[i] = closed[c]
+		}
+		for i, c := range group {
+			colors[c] = sortedColors[i]
+			closed[c] = sortedClosed[i]
+		}
+	}
+}
+
+func compareColors(a []EdgePartition, b []EdgePartition) int {
+	for i := range a {
+		if c := a[i].Compare(&b[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Map returns an EdgePartition with every position transformed by f,
+// in sorted order.
+func (e *EdgePartition) Map(f func(int) int) EdgePartition {
+	var ret EdgePartition
+	ret.Sets = make([]EdgeSet, len(e.Sets))
+	for i := range e.Sets {
+		ret.Sets[i] = make([]int, len(e.Sets[i]))
+		for j, pos := range e.Sets[i] {
+			ret.Sets[i][j] = f(pos)
+		}
+	}
+	ret.Sort()
+	return ret
+}
+
+// symmetries returns the transformations of the edge positions which
+// preserve the adjacency of the cells.
+func (g *ColorRectangle) symmetries() []func(int) int {
+	w := g.Width
+	if g.Rules.Lattice == Cubic {
+		return layerSymmetries(layerSide(w))
+	}
+	identity := func(pos int) int { return pos }
+	reflect := func(pos int) int {
+		row := pos / w * w
+		return row + (w - 1 - pos%w)
+	}
+	// Only the square lattice is symmetric from left to right; the
+	// others lean to one side.
+	reflects := g.Rules.Lattice == Square
+	if !g.Rules.Boundary.Wraps() {
+		if !reflects {
+			return []func(int) int{identity}
+		}
+		return []func(int) int{identity, reflect}
+	}
+	// A triangular lattice can only be rotated by a whole rhombus.
+	step := 1
+	if g.Rules.Lattice == Triangular {
+		step = 2
+	}
+	ret := make([]func(int) int, 0, 2*w)
+	for r := 0; r < w; r += step {
+		rotate := func(r int) func(int) int {
+			return func(pos int) int {
+				row := pos / w * w
+				return row + (pos%w+r)%w
+			}
+		}(r)
+		ret = append(ret, rotate)
+		if reflects {
+			ret = append(ret, func(pos int) int { return rotate(reflect(pos)) })
+		}
+	}
+	return ret
+}
+
+// layerSymmetries returns the rotations and reflections of a square
+// layer of cubes.
+func layerSymmetries(side int) []func(int) int {
+	ret := make([]func(int) int, 0, 8)
+	for _, transpose := range []bool{false, true} {
+		for _, flipX := range []bool{false, true} {
+			for _, flipY := range []bool{false, true} {
+				transpose, flipX, flipY := transpose, flipX, flipY
+				ret = append(ret, func(pos int) int {
+					x, y := pos%side, pos/side
+					if transpose {
+						x, y = y, x
+					}
+					if flipX {
+						x = side - 1 - x
+					}
+					if flipY {
+						y = side - 1 - y
+					}
+					return y*side + x
+				})
+			}
+		}
+	}
+	return ret
+}
+
+// MakeCanonical normalizes a colored rectangle to its canonical value,
+// from which we can derive a label.
+func (g *ColorRectangle) MakeCanonical() {
+	groups := g.Rules.colorGroups()
+	var best []EdgePartition
+	var bestClosed []bool
+	for _, f := range g.symmetries() {
+		alt := make([]EdgePartition, len(g.Colors))
+		for i := range g.Colors {
+			alt[i] = g.Colors[i].Map(f)
+		}
+		// The closed colors are the same for every symmetry, so once
+		// sorted they are the same for equal edges.
+		closed := make([]bool, len(g.Closed))
+		copy(closed, g.Closed)
+		sortColors(groups, alt, closed)
+		if best == nil || compareColors(alt, best) == -1 {
+			best = alt
+			bestClosed = closed
+		}
+	}
+	g.Colors = best
+	g.Closed = bestClosed
+}
+
+// GluedSets returns the number of sets of each color once the edge is
+// complete; for a torus, that is after the last row is glued to the first.
+func (g *ColorRectangle) GluedSets() []int {
+	ret := make([]int, len(g.Colors))
+	if g.Rules.Boundary != Torus {
+		for c := range g.Colors {
+			ret[c] = len(g.Colors[c].Sets)
+		}
+		return ret
+	}
+
+	uf := unionfind.NewMultiRowUnionFind(g.Width, 2)
+	colorMap := make([]int, 2*g.Width)
+	for c := range g.Colors {
+		for _, s := range g.Colors[c].Sets {
+			for _, pos := range s {
+				uf.Union(s[0], pos)
+				colorMap[pos] = c
+			}
+		}
+	}
+	// The first row comes after the last one.
+	w := g.Width
+	for j := 0; j < w; j++ {
+		c := colorMap[w+j]
+		for _, i := range g.Rules.Above(w, j, c) {
+			if colorMap[i] == c {
+				uf.Union(i, w+j)
+			}
+		}
+	}
+	for c := range g.Colors {
+		roots := make(map[int]bool)
+		for _, s := range g.Colors[c].Sets {
+			roots[uf.Find(s[0])] = true
+		}
+		ret[c] = len(roots)
+	}
+	return ret
+}
+
+// Key is unique to the width and number of colors, but not the height,
+// so we can re-use it
+func (g *ColorRectangle) Key() string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("%d:k%d:r%d", g.Width, g.Rules.NumColors, g.Regions))
+	for c, closed := range g.Closed {
+		if closed {
+			buf.WriteString(fmt.Sprintf(":x%d", c))
+		}
+	}
+	for c := range g.Colors {
+		for _, s := range g.Colors[c].Sets {
+			buf.WriteString(fmt.Sprintf(":%d", c))
+			for j := range s {
+				buf.WriteString(fmt.Sprintf(",%d", s[j]))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// Plot shows the color of each edge cell, followed by a letter
+// for the component it belongs to.  On a torus, the first row is
+// shown after the last one.
+func (g *ColorRectangle) Plot() string {
+	letters := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	size := g.Width
+	if g.Rules.Boundary == Torus {
+		size = 2 * g.Width
+	}
+	colors := make([]byte, size)
+	components := make([]byte, size)
+	for i := range colors {
+		colors[i] = '.'
+		components[i] = '.'
+	}
+	n := 0
+	for c := range g.Colors {
+		for _, s := range g.Colors[c].Sets {
+			for _, pos := range s {
+				colors[pos] = byte('0' + c)
+				components[pos] = letters[n%len(letters)]
+			}
+			n += 1
+		}
+	}
+	if size > g.Width {
+		return string(colors[:g.Width]) + "/" + string(colors[g.Width:]) + " " +
+			string(components[:g.Width]) + "/" + string(components[g.Width:])
+	}
+	return string(colors) + " " + string(components)
+}
+
+// Rows returns the height of the grids in this class.
+func (g *ColorRectangle) Rows() int {
+	return g.Height
+}
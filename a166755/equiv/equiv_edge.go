@@ -19,6 +19,9 @@ import (
 //         * flip along the vertical line x = (n-1)/2
 //
 // Solid boundaries are colored White.
+//
+// Both colors use the same Adjacency, so that interchanging them is
+// still a symmetry; ColorRectangle handles mixed adjacency.
 
 type GridRectangle struct {
 	Width      int
@@ -26,6 +29,7 @@ type GridRectangle struct {
 	SolidColor bool
 	White      EdgePartition
 	Black      EdgePartition
+	Adjacency  Adjacency
 }
 
 func (e *EdgePartition) MidpointFlip(width int) EdgePartition {
@@ -50,9 +54,44 @@ func (e *EdgePartition) MidpointFlip(width int) EdgePartition {
 
 }
 
+// Transform is a symmetry of a GridRectangle: a flip along the
+// vertical midline, an interchange of black and white, or both.
+// The two commute, and each is its own inverse.
+type Transform struct {
+	Flip bool
+	Swap bool
+}
+
+// Then returns the transform which applies t and then u.
+func (t Transform) Then(u Transform) Transform {
+	return Transform{t.Flip != u.Flip, t.Swap != u.Swap}
+}
+
+// Apply returns a transformed copy of a row of colors.
+func (t Transform) Apply(row []int) []int {
+	ret := make([]int, len(row))
+	for i, c := range row {
+		if t.Flip {
+			i = len(row) - 1 - i
+		}
+		if t.Swap {
+			c = 1 - c
+		}
+		ret[i] = c
+	}
+	return ret
+}
+
 // MakeCanonical normalizes a grid rectangle to its canonical value,
 // from which we can derive a label.
 func (g *GridRectangle) MakeCanonical() {
+	g.Canonicalize()
+}
+
+// Canonicalize is MakeCanonical, but also returns the transform that
+// took the original rectangle to the canonical one.
+func (g *GridRectangle) Canonicalize() Transform {
+	var t Transform
 	g.White.Sort()
 	g.Black.Sort()
 
@@ -60,6 +99,7 @@ func (g *GridRectangle) MakeCanonical() {
 	if len(g.Black.Sets) > 0 && g.Black.Sets[0][0] == 0 {
 		// Swap so that White has the smallest edge
 		g.White, g.Black = g.Black, g.White
+		t.Swap = true
 	}
 
 	extremal := g.Width - 1
@@ -69,6 +109,7 @@ func (g *GridRectangle) MakeCanonical() {
 		if alt.Compare(&g.White) == -1 {
 			g.Black = g.White.MidpointFlip(g.Width)
 			g.White = alt
+			t = t.Then(Transform{Flip: true, Swap: true})
 		}
 	} else {
 		// otherwise compare only flip
@@ -77,6 +118,7 @@ func (g *GridRectangle) MakeCanonical() {
 		case -1:
 			g.Black = g.Black.MidpointFlip(g.Width)
 			g.White = alt
+			t.Flip = !t.Flip
 		case 0:
 			// If flip(W) = W, then we might
 			// have to tie-break on flip(B)
@@ -84,9 +126,22 @@ func (g *GridRectangle) MakeCanonical() {
 			if altBlack.Compare(&g.Black) == -1 {
 				g.Black = altBlack
 				g.White = alt // equal, should not matter
+				t.Flip = !t.Flip
 			}
 		}
 	}
+	return t
+}
+
+// EdgeColors returns the color of each cell in the last row.
+func (g *GridRectangle) EdgeColors() []int {
+	ret := make([]int, g.Width)
+	for _, s := range g.Black.Sets {
+		for _, pos := range s {
+			ret[pos] = 1
+		}
+	}
+	return ret
 }
 
 // Key is unique to the width, but not the height,
@@ -121,6 +176,12 @@ func (g *GridRectangle) Key() string {
 	return buf.String()
 }
 
+// PackedKey returns the same information as Key, if the rectangle is
+// at most MaxPackedCells wide.
+func (g *GridRectangle) PackedKey() (PackedKey, bool) {
+	return packEdge(g.Width, 0, g.SolidColor, &g.White, &g.Black)
+}
+
 func (g *GridRectangle) Plot() string {
 	whiteLetters := "abcdefghijklmnopqrstuvwxyz"
 	blackLetters := "ZYXWVUTSRQPONMLKJIHGFEDCBA"
@@ -138,3 +199,8 @@ func (g *GridRectangle) Plot() string {
 	}
 	return string(lower)
 }
+
+// Rows returns the height of the grids in this class.
+func (g *GridRectangle) Rows() int {
+	return g.Height
+}
@@ -329,6 +329,7 @@ func TestRectangle_CanonicalIsInvariant(t *testing.T) {
 			false,
 			EdgePartition{white},
 			EdgePartition{black},
+			Four,
 		}
 
 		t.Logf("Testing %v plot %v", orig.Key(), orig.Plot())
@@ -398,3 +399,184 @@ func TestRectangle_CanonicalIsInvariant(t *testing.T) {
 	properties.TestingRun(t)
 
 }
+
+func TestColorRectangle_CanonicalIsInvariant(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	n := 8
+	k := 3
+	build := func(colors []int, components []int) *ColorRectangle {
+		g := NewColorRectangle(n, &Rules{NumColors: k, Boundary: Plain})
+		g.Height = 4
+		members := make(map[int][]int)
+		for i, v := range components {
+			members[v] = append(members[v], i)
+		}
+		for tag, edges := range members {
+			c := colors[tag]
+			g.Colors[c].Sets = append(g.Colors[c].Sets, edges)
+		}
+		return g
+	}
+
+	invariant := func(colorsAndComponents []int) bool {
+		// The first n integers tag each edge with a component, the
+		// last n assign each component a color.  Some of the
+		// possible partitions are impossible, but we should arrive
+		// at a correct canonical representation anyway.
+		components := colorsAndComponents[:n]
+		colors := colorsAndComponents[n:]
+
+		unmodified := build(colors, components)
+		t.Logf("Testing %v plot %v", unmodified.Key(), unmodified.Plot())
+		unmodified.MakeCanonical()
+
+		permuted := make([]int, n)
+		for i := range colors {
+			permuted[i] = (colors[i] + 1) % k
+		}
+		colorSwap := build(permuted, components)
+		colorSwap.MakeCanonical()
+		if !reflect.DeepEqual(unmodified, colorSwap) {
+			t.Logf("color permutation failed: %v != %v",
+				unmodified.Key(), colorSwap.Key())
+			return false
+		}
+
+		reversed := make([]int, n)
+		for i := range components {
+			reversed[i] = components[n-1-i]
+		}
+		flip := build(colors, reversed)
+		flip.MakeCanonical()
+		if !reflect.DeepEqual(unmodified, flip) {
+			t.Logf("flip failed: %v != %v",
+				unmodified.Key(), flip.Key())
+			return false
+		}
+
+		both := build(permuted, reversed)
+		both.MakeCanonical()
+		if !reflect.DeepEqual(unmodified, both) {
+			t.Logf("both failed: %v != %v",
+				unmodified.Key(), both.Key())
+			return false
+		}
+		return true
+	}
+	properties.Property("invariant under transformations",
+		prop.ForAll(invariant,
+			gen.SliceOfN(2*n, gen.IntRange(0, k-1)),
+		))
+	properties.TestingRun(t)
+}
+
+func TestColorRectangle_CanonicalIsRotationInvariant(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	n := 7
+	k := 2
+	build := func(colors []int, components []int) *ColorRectangle {
+		g := NewColorRectangle(n, &Rules{NumColors: k, Boundary: Torus})
+		g.Height = 4
+		members := make(map[int][]int)
+		for i, v := range components {
+			members[v] = append(members[v], i)
+		}
+		for tag, edges := range members {
+			c := colors[tag]
+			g.Colors[c].Sets = append(g.Colors[c].Sets, edges)
+		}
+		return g
+	}
+
+	invariant := func(colorsAndComponents []int, rotation int) bool {
+		// As above, but for both the last row and the first row
+		// of a torus, which must be rotated together.
+		components := colorsAndComponents[:2*n]
+		colors := colorsAndComponents[2*n:]
+
+		unmodified := build(colors, components)
+		t.Logf("Testing %v plot %v", unmodified.Key(), unmodified.Plot())
+		unmodified.MakeCanonical()
+
+		rotated := make([]int, 2*n)
+		for i := range components {
+			row := i / n * n
+			rotated[row+(i%n+rotation)%n] = components[i]
+		}
+		rotate := build(colors, rotated)
+		rotate.MakeCanonical()
+		if !reflect.DeepEqual(unmodified, rotate) {
+			t.Logf("rotation failed: %v != %v",
+				unmodified.Key(), rotate.Key())
+			return false
+		}
+
+		reversed := make([]int, 2*n)
+		for i := range components {
+			row := i / n * n
+			reversed[row+(n-1-i%n)] = rotated[i]
+		}
+		both := build(colors, reversed)
+		both.MakeCanonical()
+		if !reflect.DeepEqual(unmodified, both) {
+			t.Logf("rotation and flip failed: %v != %v",
+				unmodified.Key(), both.Key())
+			return false
+		}
+		return true
+	}
+	properties.Property("invariant under rotation",
+		prop.ForAll(invariant,
+			gen.SliceOfN(4*n, gen.IntRange(0, k-1)),
+			gen.IntRange(0, n-1),
+		))
+	properties.TestingRun(t)
+}
+
+func TestPackedKey_MatchesKey(t *testing.T) {
+	properties := gopter.NewProperties(nil)
+
+	// Small grids, so that the classes often agree
+	invariant := func(a [][]int, b [][]int) bool {
+		ra := RectangleClassForGrid(3, 3, a)
+		rb := RectangleClassForGrid(3, 3, b)
+		ra.MakeCanonical()
+		rb.MakeCanonical()
+		pa, _ := ra.PackedKey()
+		pb, _ := rb.PackedKey()
+		if (ra.Key() == rb.Key()) != (pa == pb) {
+			t.Logf("rectangles %v and %v packed as %v and %v", ra.Key(), rb.Key(), pa, pb)
+			return false
+		}
+
+		ga := EdgeClassForGrid(3, a)
+		gb := EdgeClassForGrid(3, b)
+		ga.MakeCanonical()
+		gb.MakeCanonical()
+		pa, _ = ga.PackedKey()
+		pb, _ = gb.PackedKey()
+		if (ga.Key() == gb.Key()) != (pa == pb) {
+			t.Logf("boundaries %v and %v packed as %v and %v", ga.Key(), gb.Key(), pa, pb)
+			return false
+		}
+		return true
+	}
+	properties.Property("packed keys agree with keys",
+		prop.ForAll(invariant,
+			gen.SliceOfN(3, gen.SliceOfN(3, gen.IntRange(0, 1))),
+			gen.SliceOfN(3, gen.SliceOfN(3, gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestPackedKey_TooWide(t *testing.T) {
+	g := &GridRectangle{
+		Width: MaxPackedCells + 1,
+		White: EdgePartition{[][]int{{0}}},
+	}
+	if _, ok := g.PackedKey(); ok {
+		t.Errorf("expected no packed key for width %v", g.Width)
+	}
+}
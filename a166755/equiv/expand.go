@@ -173,6 +173,13 @@ func (g *GridBoundary) Expand(newBorder []int) *GridBoundary {
 // Expand the height by 1 and return the normalized GridRectangle
 // the argument is a map of position -> 0 for white, 1 for black
 func (g *GridRectangle) Expand(newBorder []int) *GridRectangle {
+	ret, _ := g.ExpandTransform(newBorder)
+	return ret
+}
+
+// ExpandTransform is Expand, but also returns the transform that took
+// the expanded rectangle to the normalized one.
+func (g *GridRectangle) ExpandTransform(newBorder []int) (*GridRectangle, Transform) {
 	if len(newBorder) != g.Width {
 		panic("incomplete border")
 	}
@@ -212,6 +219,15 @@ func (g *GridRectangle) Expand(newBorder []int) *GridRectangle {
 		if i > 0 && newBorder[i] == newBorder[i-1] {
 			uf.UnionCell(1, i, 1, i-1)
 		}
+		// Same color as a cell diagonally above?
+		if g.Adjacency == Eight {
+			if i > 0 && newBorder[i] == colorMap[i-1] {
+				uf.UnionCell(0, i-1, 1, i)
+			}
+			if i < g.Width-1 && newBorder[i] == colorMap[i+1] {
+				uf.UnionCell(0, i+1, 1, i)
+			}
+		}
 	}
 
 	whiteMap := make(map[int][]int)
@@ -252,12 +268,165 @@ func (g *GridRectangle) Expand(newBorder []int) *GridRectangle {
 		SolidColor: false,
 		White:      EdgePartition{Sets: white},
 		Black:      EdgePartition{Sets: black},
+		Adjacency:  g.Adjacency,
 	}
 
 	if g.SolidColor && len(black) == 0 {
 		ret.SolidColor = true
 	}
 
+	return ret, ret.Canonicalize()
+}
+
+// Continues checks whether every set on the edge reaches the new border.
+func (g *GridRectangle) Continues(newBorder []int) bool {
+	reach := 0
+	if g.Adjacency == Eight {
+		reach = 1
+	}
+	continues := func(s EdgeSet, color int) bool {
+		for _, pos := range s {
+			for i := pos - reach; i <= pos+reach; i++ {
+				if i >= 0 && i < g.Width && newBorder[i] == color {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, s := range g.White.Sets {
+		if !continues(s, 0) {
+			return false
+		}
+	}
+	for _, s := range g.Black.Sets {
+		if !continues(s, 1) {
+			return false
+		}
+	}
+	return true
+}
+
+// Expand the height by 1 and return the normalized ColorRectangle.
+// The argument is the color of each cell in the new row, using the
+// color numbering of g.
+func (g *ColorRectangle) Expand(newRow []int) *ColorRectangle {
+	if len(newRow) != g.Width {
+		panic("incomplete border")
+	}
+	w := g.Width
+
+	// use union-find to track partitions; row 0 is the old edge,
+	// row 1 the new one, and row 2 the first row of a torus.
+	uf := unionfind.NewMultiRowUnionFind(w, 3)
+	cell := func(pos int) (int, int) {
+		if pos >= w {
+			return 2, pos - w
+		}
+		return 0, pos
+	}
+
+	// Cells with no color are above the first row.
+	colorMap := make([]int, 2*w)
+	for i := range colorMap {
+		colorMap[i] = -1
+	}
+
+	for c := range g.Colors {
+		for _, s := range g.Colors[c].Sets {
+			y0, x0 := cell(s[0])
+			colorMap[s[0]] = c
+			for i := 1; i < len(s); i++ {
+				y, x := cell(s[i])
+				uf.UnionCell(y0, x0, y, x)
+				colorMap[s[i]] = c
+			}
+		}
+	}
+
+	// The first row of a torus is remembered until the end.
+	torus := g.Rules.Boundary == Torus
+	if torus && g.Height == 0 {
+		for i := 0; i < w; i++ {
+			colorMap[w+i] = newRow[i]
+			uf.UnionCell(1, i, 2, i)
+		}
+	}
+
+	// New bottom cells
+	for i := 0; i < w; i++ {
+		// Same color as a cell above?
+		for _, j := range g.Rules.Above(w, i, newRow[i]) {
+			if newRow[i] == colorMap[j] {
+				uf.UnionCell(0, j, 1, i)
+			}
+		}
+		// Same color as a cell beside it?
+		for _, j := range g.Rules.Beside(w, i) {
+			if newRow[i] == newRow[j] {
+				uf.UnionCell(1, i, 1, j)
+			}
+		}
+	}
+
+	// Any set which is not connected to the new row (or the first
+	// row of a torus) is finished.
+	continued := make(map[int]bool)
+	for i := 0; i < w; i++ {
+		continued[uf.FindCell(1, i)] = true
+		if torus {
+			continued[uf.FindCell(2, i)] = true
+		}
+	}
+	finished := 0
+	for c := range g.Colors {
+		if !g.Rules.countsRegion(c) {
+			continue
+		}
+		for _, s := range g.Colors[c].Sets {
+			if !continued[uf.FindCell(cell(s[0]))] {
+				finished += 1
+			}
+		}
+	}
+
+	setMaps := make([]map[int][]int, g.Rules.NumColors)
+	for c := range setMaps {
+		setMaps[c] = make(map[int][]int)
+	}
+	for i := 0; i < w; i++ {
+		r := uf.FindCell(1, i)
+		setMaps[newRow[i]][r] = append(setMaps[newRow[i]][r], i)
+	}
+	if torus {
+		for i := 0; i < w; i++ {
+			r := uf.FindCell(2, i)
+			c := colorMap[w+i]
+			setMaps[c][r] = append(setMaps[c][r], w+i)
+		}
+	}
+
+	ret := &ColorRectangle{
+		Width:   w,
+		Height:  g.Height + 1,
+		Rules:   g.Rules,
+		Regions: g.Regions + finished,
+		Closed:  make([]bool, g.Rules.NumColors),
+		Colors:  make([]EdgePartition, g.Rules.NumColors),
+	}
+	for c := range setMaps {
+		sets := make([]EdgeSet, 0, len(setMaps[c]))
+		for _, s := range setMaps[c] {
+			sets = append(sets, s)
+		}
+		ret.Colors[c].Sets = sets
+
+		// A color which leaves the edge is closed.
+		if g.Rules.ConstraintOf(c) == Connected && len(sets) == 0 {
+			ret.Closed[c] = g.Closed[c] || len(g.Colors[c].Sets) > 0
+		}
+	}
+
 	ret.MakeCanonical()
 	return ret
 }
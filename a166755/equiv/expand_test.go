@@ -161,6 +161,41 @@ func TestRectangle_5x3(t *testing.T) {
 	properties.TestingRun(t)
 }
 
+func TestRectangle_ExpandTransform(t *testing.T) {
+	// Follow a random 5x5 grid through the canonical classes of its
+	// first four and then five rows; the transforms should take the
+	// grid to one whose own class is the canonical one.
+	invariant := func(grid [][]int) bool {
+		class4 := RectangleClassForGrid(5, 4, grid)
+		t4 := class4.Canonicalize()
+		actual, u := class4.ExpandTransform(t4.Apply(grid[4]))
+
+		transform := t4.Then(u)
+		moved := make([][]int, len(grid))
+		for y := range grid {
+			moved[y] = transform.Apply(grid[y])
+		}
+		expected := RectangleClassForGrid(5, 5, moved)
+		expected.White.Sort()
+		expected.Black.Sort()
+		if !reflect.DeepEqual(actual, expected) {
+			t.Logf("Grid:\n%v", ShowGrid(5, grid))
+			t.Logf("Transform: %v then %v", t4, u)
+			t.Logf("Actual: %v\n%v", actual, actual.Plot())
+			t.Logf("Expected: %v\n%v", expected, expected.Plot())
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("transforms match DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(5, gen.SliceOfN(5, gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
 func TestRectangle_ExpandSolid(t *testing.T) {
 	firstRow := &GridRectangle{
 		Width:      4,
@@ -209,3 +244,348 @@ func TestRectangle_ExpandSolid(t *testing.T) {
 	}
 
 }
+
+// countingRegions changes the rules to count every region, of any color.
+func countingRegions(rules *Rules) *Rules {
+	rules.Constraints = make([]Constraint, rules.NumColors)
+	for c := range rules.Constraints {
+		rules.Constraints[c] = Unrestricted
+	}
+	rules.CountRegions = true
+	return rules
+}
+
+func checkColorExpansion(t *testing.T, rules *Rules) {
+	// Take a random 3-colored 5x5 grid
+	// Compute the equivalence classes for the 5x4 subset
+	// and the 5x5, then ensure the expansion matches.
+	invariant := func(grid [][]int) bool {
+		class4 := ColorClassForGrid(5, 4, rules, grid)
+		class5 := ColorClassForGrid(5, 5, rules, grid)
+		boundary := grid[4]
+		t.Logf("Grid:\n%v", grid)
+		t.Logf("4x5 class: %v", class4.Plot())
+		t.Logf("5x5 class: %v", class5.Plot())
+
+		actual := class4.Expand(boundary)
+		t.Logf("Actual: %v %v", actual.Key(), actual.Plot())
+		class5.MakeCanonical()
+		if !reflect.DeepEqual(actual, class5) {
+			t.Logf("Expected: %v %v", class5.Key(), class5.Plot())
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("expansion matches DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(5, gen.SliceOfN(rules.Lattice.RowCells(5), gen.IntRange(0, rules.NumColors-1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestColorRectangle_5x3(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Plain}))
+}
+
+func TestColorRectangle_Cylinder(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Cylinder}))
+}
+
+func TestColorRectangle_Torus(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Torus}))
+}
+
+func TestColorRectangle_Connected(t *testing.T) {
+	rules := &Rules{NumColors: 3, Boundary: Plain}
+	checkColorExpansion(t, rules)
+
+	// Build every 3x3 grid row by row, rejecting any row which
+	// reuses a closed color or leaves part of a color behind.
+	// Each color must then form exactly one region.
+	rows := make([][]int, 0, 27)
+	for i := 0; i < 27; i++ {
+		rows = append(rows, []int{i % 3, i / 3 % 3, i / 9})
+	}
+	count := 0
+	var walk func(g *ColorRectangle)
+	walk = func(g *ColorRectangle) {
+		if g.Height == 3 {
+			if g.Regions+g.NumEdgeSets() == 3 && g.NumEdgeColors() == g.NumEdgeSets() {
+				count += 1
+			}
+			return
+		}
+	nextRow:
+		for _, row := range rows {
+			for _, c := range row {
+				if g.Closed[c] {
+					continue nextRow
+				}
+			}
+			child := g.Expand(row)
+			// The child may be renumbered, so compare the number of
+			// closed colors rather than which ones they are.
+			closing := 0
+			for c := range child.Closed {
+				if child.Closed[c] {
+					closing += 1
+				}
+				if g.Closed[c] {
+					closing -= 1
+				}
+			}
+			if child.Regions == g.Regions+closing {
+				walk(child)
+			}
+		}
+	}
+	walk(NewColorRectangle(3, rules))
+	if count != 1548 {
+		t.Errorf("expected 1548 connected 3-colorings of 3x3, got %v", count)
+	}
+}
+
+func TestColorRectangle_Eight(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{
+		NumColors: 3,
+		Boundary:  Plain,
+		Adjacency: []Adjacency{Eight, Eight, Eight},
+	}))
+}
+
+func TestColorRectangle_Mixed(t *testing.T) {
+	checkColorExpansion(t, &Rules{
+		NumColors: 2,
+		Boundary:  Plain,
+		Adjacency: []Adjacency{Four, Eight},
+	})
+}
+
+func TestColorRectangle_MixedTorus(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{
+		NumColors: 3,
+		Boundary:  Torus,
+		Adjacency: []Adjacency{Eight, Four, Eight},
+	}))
+}
+
+func TestColorRectangle_Cubic(t *testing.T) {
+	checkColorExpansion(t, &Rules{NumColors: 2, Boundary: Plain, Lattice: Cubic})
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Plain, Lattice: Cubic}))
+}
+
+func TestColorRectangle_Unrestricted(t *testing.T) {
+	checkColorExpansion(t, &Rules{
+		NumColors:   2,
+		Boundary:    Plain,
+		Constraints: []Constraint{Unrestricted, Connected},
+	})
+}
+
+func TestColorRectangle_UnrestrictedTorus(t *testing.T) {
+	checkColorExpansion(t, &Rules{
+		NumColors:   3,
+		Boundary:    Torus,
+		Constraints: []Constraint{Connected, Unrestricted, Connected},
+	})
+}
+
+func TestColorRectangle_Hexagonal(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Plain, Lattice: Hexagonal}))
+}
+
+func TestColorRectangle_HexagonalCylinder(t *testing.T) {
+	checkColorExpansion(t, &Rules{NumColors: 2, Boundary: Cylinder, Lattice: Hexagonal})
+}
+
+func TestColorRectangle_Triangular(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 3, Boundary: Plain, Lattice: Triangular}))
+}
+
+func TestColorRectangle_TriangularTorus(t *testing.T) {
+	checkColorExpansion(t, countingRegions(&Rules{NumColors: 2, Boundary: Torus, Lattice: Triangular}))
+}
+
+func checkGluedSets(t *testing.T, rules *Rules) {
+	// Gluing the last row of a torus to the first should give
+	// the same components as a DFS that wraps around.
+	invariant := func(grid [][]int) bool {
+		class := ColorClassForGrid(4, 3, rules, grid)
+		glued := class.GluedSets()
+
+		board := NewBoard(4, 3, rules)
+		w := board.Width
+		colors := make([]int, 3*w)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < w; x++ {
+				colors[y*w+x] = grid[y][x]
+			}
+		}
+		visited := make([]bool, 3*w)
+		expected := make([]int, 2)
+		for i, c := range colors {
+			component := board.ConnectedComponent(colors, Coord{i%w + 1, i/w + 1}, visited)
+			if len(component) > 0 {
+				expected[c] += 1
+			}
+		}
+		// Regions which never touched the first or last row are
+		// not part of the edge, but are counted in class.Regions.
+		total := 0
+		for c := range glued {
+			total += glued[c]
+		}
+		if total+class.Regions != expected[0]+expected[1] {
+			t.Logf("Grid %v: glued %v + %v, expected %v", grid, glued, class.Regions, expected)
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("gluing matches DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(3, gen.SliceOfN(rules.Lattice.RowCells(4), gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestColorRectangle_GluedSets(t *testing.T) {
+	checkGluedSets(t, &Rules{NumColors: 2, Boundary: Torus})
+}
+
+func TestColorRectangle_GluedSetsMixed(t *testing.T) {
+	checkGluedSets(t, &Rules{NumColors: 2, Boundary: Torus, Adjacency: []Adjacency{Eight, Four}})
+}
+
+func TestColorRectangle_GluedSetsHexagonal(t *testing.T) {
+	checkGluedSets(t, &Rules{NumColors: 2, Boundary: Torus, Lattice: Hexagonal})
+}
+
+func TestColorRectangle_GluedSetsTriangular(t *testing.T) {
+	checkGluedSets(t, &Rules{NumColors: 2, Boundary: Torus, Lattice: Triangular})
+}
+
+func TestColorRectangle_ExpandEmpty(t *testing.T) {
+	rules := &Rules{NumColors: 3, Boundary: Plain}
+	empty := NewColorRectangle(4, rules)
+	actual := empty.Expand([]int{2, 2, 0, 2})
+	expected := &ColorRectangle{
+		Width:   4,
+		Height:  1,
+		Rules:   rules,
+		Regions: 0,
+		Closed:  []bool{false, false, false},
+		Colors: []EdgePartition{
+			EdgePartition{[][]int{[]int{0}, []int{2, 3}}},
+			EdgePartition{[][]int{[]int{1}}},
+			EdgePartition{[][]int{}},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expansion %v does not match expected %v", actual.Key(), expected.Key())
+	}
+
+	// Closing off color 1 leaves only color 0 on the edge.
+	next := actual.Expand([]int{0, 0, 0, 0})
+	if next.Regions != 1 || next.NumEdgeColors() != 1 {
+		t.Errorf("expected one finished region, got %v", next.Key())
+	}
+	if unused := next.UnusedColors(); len(unused) != 1 || unused[0] != 2 {
+		t.Errorf("expected color 2 unused, got %v", unused)
+	}
+}
+
+func TestColorRectangle_ExpandMixed(t *testing.T) {
+	// With black 8-connected and white 4-connected, the black
+	// cells meet diagonally but the white ones do not.
+	//  .X
+	//  X.
+	rules := &Rules{NumColors: 2, Boundary: Plain, Adjacency: []Adjacency{Four, Eight}}
+	first := NewColorRectangle(2, rules).Expand([]int{0, 1})
+	second := first.Expand([]int{1, 0})
+	if second.Regions != 1 || len(second.Colors[0].Sets) != 1 || len(second.Colors[1].Sets) != 1 {
+		t.Errorf("expected one finished white region, got %v", second.Key())
+	}
+
+	// Black may not be renumbered as white.
+	solid := NewColorRectangle(2, rules).Expand([]int{1, 1})
+	if len(solid.Colors[1].Sets) != 1 {
+		t.Errorf("colors should not be swapped, got %v", solid.Key())
+	}
+}
+
+func TestRectangle_Eight(t *testing.T) {
+	// As TestRectangle_5x3, with 8-connectivity.
+	invariant := func(grid [][]int) bool {
+		class4 := RectangleClassForBoard(Board{Width: 5, Height: 4, Adjacency: []Adjacency{Eight, Eight}}, grid)
+		class5 := RectangleClassForBoard(Board{Width: 5, Height: 5, Adjacency: []Adjacency{Eight, Eight}}, grid)
+
+		actual := class4.Expand(grid[4])
+		class5.MakeCanonical()
+		if !reflect.DeepEqual(actual, class5) {
+			t.Logf("Grid:\n%v", ShowGrid(5, grid))
+			t.Logf("Actual: %v Expected: %v", actual.Key(), class5.Key())
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("expansion matches DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(5, gen.SliceOfN(5, gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestRectangle_ContinuesDiagonally(t *testing.T) {
+	grid := [][]int{[]int{0, 1}}
+	four := RectangleClassForBoard(Board{Width: 2, Height: 1}, grid)
+	eight := RectangleClassForBoard(Board{Width: 2, Height: 1, Adjacency: []Adjacency{Eight, Eight}}, grid)
+	if four.Continues([]int{1, 0}) {
+		t.Errorf("4-connected sets should not continue diagonally")
+	}
+	if !eight.Continues([]int{1, 0}) {
+		t.Errorf("8-connected sets should continue diagonally")
+	}
+}
+
+func checkInterfaceLength(t *testing.T, rules *Rules) {
+	// Adding up the interface one row at a time should match
+	// the whole board.
+	invariant := func(grid [][]int) bool {
+		board := NewBoard(5, 4, rules)
+		colors := make([]int, 0, board.Width*board.Height)
+		total := 0
+		var prev []int
+		for _, row := range grid {
+			colors = append(colors, row...)
+			total += rules.InterfaceLength(prev, row)
+			prev = row
+		}
+		if expected := board.InterfaceLength(colors); total != expected {
+			t.Logf("Grid:\n%v", grid)
+			t.Logf("Rows add up to %v, expected %v", total, expected)
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("interface matches board",
+		prop.ForAll(invariant,
+			gen.SliceOfN(4, gen.SliceOfN(rules.Lattice.RowCells(5), gen.IntRange(0, rules.NumColors-1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestRules_InterfaceLength(t *testing.T) {
+	checkInterfaceLength(t, &Rules{NumColors: 2, Boundary: Plain})
+	checkInterfaceLength(t, &Rules{NumColors: 3, Boundary: Cylinder, Adjacency: []Adjacency{Eight, Four, Four}})
+	checkInterfaceLength(t, &Rules{NumColors: 2, Boundary: Cylinder, Lattice: Hexagonal})
+	checkInterfaceLength(t, &Rules{NumColors: 2, Boundary: Plain, Lattice: Triangular})
+	checkInterfaceLength(t, &Rules{NumColors: 2, Boundary: Plain, Lattice: Cubic})
+}
@@ -0,0 +1,341 @@
+package equiv
+
+import (
+	"fmt"
+)
+
+// Boundary conditions for the left and right sides, and the
+// top and bottom.
+type Boundary int
+
+const (
+	Plain Boundary = iota
+	Cylinder
+	Torus
+)
+
+func (b Boundary) String() string {
+	switch b {
+	case Plain:
+		return "plain"
+	case Cylinder:
+		return "cylinder"
+	case Torus:
+		return "torus"
+	}
+	return fmt.Sprintf("Boundary(%d)", int(b))
+}
+
+// Wraps is true if column 0 is adjacent to column Width-1.
+func (b Boundary) Wraps() bool {
+	return b == Cylinder || b == Torus
+}
+
+// Adjacency says which neighbors of a cell are in the same region,
+// if they are the same color.
+type Adjacency int
+
+const (
+	// Only the cells sharing a side
+	Four Adjacency = iota
+	// The diagonal cells as well
+	Eight
+)
+
+func (a Adjacency) String() string {
+	switch a {
+	case Four:
+		return "4"
+	case Eight:
+		return "8"
+	}
+	return fmt.Sprintf("Adjacency(%d)", int(a))
+}
+
+// Constraint is what is required of the cells of one color.
+type Constraint int
+
+const (
+	// The color is used, and forms a single region
+	Connected Constraint = iota
+	// Any number of regions, including none
+	Unrestricted
+	// The color is never used
+	Absent
+)
+
+func (c Constraint) String() string {
+	switch c {
+	case Connected:
+		return "connected"
+	case Unrestricted:
+		return "unrestricted"
+	case Absent:
+		return "absent"
+	}
+	return fmt.Sprintf("Constraint(%d)", int(c))
+}
+
+// Lattice is the shape of the cells.  Every lattice is counted on an
+// n x n rhombus, built row by row:
+//
+//	Square      each cell has 4 neighbors (or 8, see Adjacency)
+//	Hexagonal   each row is offset by half a cell from the one above,
+//	            so cell i touches cells i and i+1 of the row above
+//	Triangular  each rhombus is split into two triangles, so a row of
+//	            n rhombi is a row of 2n triangles: the even ones point
+//	            up and touch the odd one to their right in the row above
+//	Cubic       each "row" is an n x n layer of cubes, numbered across
+//	            and then down, so an n x h grid is an n x n x h box; a
+//	            cube touches the one directly above it
+type Lattice int
+
+const (
+	Square Lattice = iota
+	Hexagonal
+	Triangular
+	Cubic
+)
+
+func (l Lattice) String() string {
+	switch l {
+	case Square:
+		return "square"
+	case Hexagonal:
+		return "hexagonal"
+	case Triangular:
+		return "triangular"
+	case Cubic:
+		return "cubic"
+	}
+	return fmt.Sprintf("Lattice(%d)", int(l))
+}
+
+// RowCells returns the number of cells in one row of a rhombus
+// of the given width.
+func (l Lattice) RowCells(width int) int {
+	switch l {
+	case Triangular:
+		return 2 * width
+	case Cubic:
+		return width * width
+	}
+	return width
+}
+
+// layerSide returns the width of a Cubic layer with this many cells.
+func layerSide(rowCells int) int {
+	side := 1
+	for side*side < rowCells {
+		side += 1
+	}
+	if side*side != rowCells {
+		panic("layer is not square")
+	}
+	return side
+}
+
+// Rules describe the grids being counted.  They are shared by every
+// ColorRectangle in an enumeration.
+type Rules struct {
+	NumColors int
+	Boundary  Boundary
+	Lattice   Lattice
+
+	// The adjacency of each color on a Square lattice; if nil,
+	// every color uses Four.
+	Adjacency []Adjacency
+
+	// The constraint on each color; if nil, every color is Connected.
+	// A Connected color which has left the edge may not be used again.
+	Constraints []Constraint
+
+	// Whether ColorRectangle.Regions counts the finished regions of
+	// every color, rather than only those of Connected colors.
+	CountRegions bool
+
+	// Whether the cells of color 1 (black) are being counted, so that
+	// it may not be interchanged with any other color.
+	CountBlack bool
+}
+
+// AdjacencyOf returns the adjacency used by one color.
+func (r *Rules) AdjacencyOf(color int) Adjacency {
+	if r.Adjacency == nil {
+		return Four
+	}
+	return r.Adjacency[color]
+}
+
+// ConstraintOf returns the constraint on one color.
+func (r *Rules) ConstraintOf(color int) Constraint {
+	if r.Constraints == nil {
+		return Connected
+	}
+	return r.Constraints[color]
+}
+
+// NumConnected returns the number of Connected colors.
+func (r *Rules) NumConnected() int {
+	n := 0
+	for c := 0; c < r.NumColors; c++ {
+		if r.ConstraintOf(c) == Connected {
+			n += 1
+		}
+	}
+	return n
+}
+
+// countsRegion is true if a finished region of this color is
+// included in ColorRectangle.Regions.
+func (r *Rules) countsRegion(color int) bool {
+	return r.CountRegions || r.ConstraintOf(color) == Connected
+}
+
+// Above returns the positions in the previous row which are adjacent
+// to position i of a new row, for a cell of the given color.
+func (r *Rules) Above(rowCells int, i int, color int) []int {
+	return r.above(rowCells, i, r.AdjacencyOf(color))
+}
+
+// Touching returns the positions in the previous row which share a
+// side with position i of a new row, whatever their colors.
+func (r *Rules) Touching(rowCells int, i int) []int {
+	return r.above(rowCells, i, Four)
+}
+
+func (r *Rules) above(rowCells int, i int, adjacency Adjacency) []int {
+	var offsets []int
+	switch r.Lattice {
+	case Square:
+		if adjacency == Eight {
+			offsets = []int{-1, 0, 1}
+		} else {
+			offsets = []int{0}
+		}
+	case Cubic:
+		offsets = []int{0}
+	case Hexagonal:
+		offsets = []int{0, 1}
+	case Triangular:
+		if i%2 == 0 {
+			offsets = []int{1}
+		}
+	}
+
+	ret := make([]int, 0, len(offsets))
+	for _, d := range offsets {
+		j := i + d
+		if j < 0 || j >= rowCells {
+			if !r.Boundary.Wraps() || rowCells == 1 {
+				continue
+			}
+			j = (j + rowCells) % rowCells
+		}
+		ret = append(ret, j)
+	}
+	return ret
+}
+
+// Beside returns the positions before i in the same row which are
+// adjacent to it; on a cylinder, the last position comes back around
+// to the first.
+func (r *Rules) Beside(rowCells int, i int) []int {
+	ret := make([]int, 0, 2)
+	if r.Lattice == Cubic {
+		side := layerSide(rowCells)
+		if i%side > 0 {
+			ret = append(ret, i-1)
+		}
+		if i >= side {
+			ret = append(ret, i-side)
+		}
+		return ret
+	}
+	if i > 0 {
+		ret = append(ret, i-1)
+	}
+	if i == rowCells-1 && r.Boundary.Wraps() && rowCells > 1 {
+		ret = append(ret, 0)
+	}
+	return ret
+}
+
+// InterfaceLength counts the sides shared by cells of different colors
+// in a new row, or between the new row and the previous one (which is
+// nil for the first row.)  On a torus, the sides between the last row
+// and the first are not included.
+func (r *Rules) InterfaceLength(prev []int, row []int) int {
+	w := len(row)
+	n := 0
+	for i := 0; i < w; i++ {
+		for _, j := range r.Beside(w, i) {
+			if row[i] != row[j] {
+				n += 1
+			}
+		}
+		if prev != nil {
+			for _, j := range r.Touching(w, i) {
+				if row[i] != prev[j] {
+					n += 1
+				}
+			}
+		}
+	}
+	return n
+}
+
+// DirectlyAbove is true if a cell of this color is only adjacent to
+// the cell directly above it in the previous row.
+func (r *Rules) DirectlyAbove(color int) bool {
+	return r.Lattice == Cubic || (r.Lattice == Square && r.AdjacencyOf(color) == Four)
+}
+
+// Oriented is true if the width and height play different roles, so
+// the grid cannot be transposed: only a cylinder, or a box of cubes,
+// is not symmetric in its width and height.
+func (r *Rules) Oriented() bool {
+	return r.Boundary == Cylinder || r.Lattice == Cubic
+}
+
+// Interchangeable checks whether two colors may be swapped without
+// changing which grids are counted.
+func (r *Rules) Interchangeable(a int, b int) bool {
+	if r.CountBlack && a != b && (a == 1 || b == 1) {
+		return false
+	}
+	return r.AdjacencyOf(a) == r.AdjacencyOf(b) && r.ConstraintOf(a) == r.ConstraintOf(b)
+}
+
+// colorGroups partitions the colors into sets of interchangeable
+// colors, each in increasing order.
+func (r *Rules) colorGroups() [][]int {
+	groups := make([][]int, 0, 1)
+	for c := 0; c < r.NumColors; c++ {
+		found := false
+		for i, g := range groups {
+			if r.Interchangeable(g[0], c) {
+				groups[i] = append(groups[i], c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []int{c})
+		}
+	}
+	return groups
+}
+
+// NewBoard returns a Board for a width x height rhombus following
+// these rules.
+func NewBoard(width int, height int, rules *Rules) Board {
+	return Board{
+		Width:     rules.Lattice.RowCells(width),
+		Lattice:   rules.Lattice,
+		Height:    height,
+		WrapX:     rules.Boundary.Wraps(),
+		WrapY:     rules.Boundary == Torus,
+		Adjacency: rules.Adjacency,
+	}
+}
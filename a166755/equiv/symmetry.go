@@ -0,0 +1,111 @@
+package equiv
+
+import (
+	"github.com/mgritter/oeis/a166755/unionfind"
+)
+
+// Grids which are fixed by a rotation are made of copies of one
+// piece, turned around the center.  These functions check whether
+// the copies of a class together form exactly two regions.
+//
+// If the grid has even size, the copies meet along their edges.  If
+// it has odd size, the copies overlap: the edges are the same cells,
+// which must agree on their colors.
+
+// oneRegionPerColor checks that the cells (numbered as in uf) have
+// exactly one region of each color.
+func oneRegionPerColor(uf *unionfind.RowUnionFind, colors []int) bool {
+	roots := make([]map[int]bool, 2)
+	for c := range roots {
+		roots[c] = make(map[int]bool)
+	}
+	for i, c := range colors {
+		roots[c][uf.Find(i)] = true
+	}
+	return len(roots[0]) == 1 && len(roots[1]) == 1
+}
+
+func swapColor(c int, swap bool) int {
+	if swap {
+		return 1 - c
+	}
+	return c
+}
+
+// HalfTurnValid checks whether this rectangle, together with a copy
+// turned 180 degrees below it (with the colors swapped if swap), has
+// exactly two regions.  If overlap, the last row of the rectangle is
+// also the last row of the copy.
+func (g *GridRectangle) HalfTurnValid(swap bool, overlap bool) bool {
+	// A region which no longer touches the edge would appear twice.
+	if len(g.Black.Sets) == 0 && !g.SolidColor {
+		return false
+	}
+
+	// Row 0 is the edge, row 1 the edge of the copy; position x of
+	// the copy is position w-1-x of the original.
+	w := g.Width
+	uf := unionfind.NewMultiRowUnionFind(w, 2)
+	colors := make([]int, 2*w)
+	for c, part := range []EdgePartition{g.White, g.Black} {
+		for _, s := range part.Sets {
+			for _, pos := range s {
+				uf.UnionCell(0, s[0], 0, pos)
+				uf.UnionCell(1, w-1-s[0], 1, w-1-pos)
+				colors[pos] = c
+				colors[w+w-1-pos] = swapColor(c, swap)
+			}
+		}
+	}
+
+	for x := 0; x < w; x++ {
+		if colors[x] == colors[w+x] {
+			uf.UnionCell(0, x, 1, x)
+		} else if overlap {
+			return false
+		}
+	}
+	return oneRegionPerColor(uf, colors)
+}
+
+// QuarterTurnValid checks whether four copies of this square, each
+// turned 90 degrees from the last (with the colors swapped each time
+// if swap), have exactly two regions.  The copies meet at the corner
+// (position 0) so that position p of each copy's edge is next to
+// position -p of the next copy.  If overlap, they are the same cell.
+func (g *GridBoundary) QuarterTurnValid(swap bool, overlap bool) bool {
+	// A region which no longer touches the edge would appear four times.
+	if len(g.Black.Sets) == 0 && !g.SolidColor {
+		return false
+	}
+
+	// Each copy is a row, with position p at p+offset.
+	offset := g.Size - 1
+	n := 2*g.Size - 1
+	uf := unionfind.NewMultiRowUnionFind(n, 4)
+	colors := make([]int, 4*n)
+	for k := 0; k < 4; k++ {
+		for c, part := range []EdgePartition{g.White, g.Black} {
+			for _, s := range part.Sets {
+				for _, pos := range s {
+					uf.UnionCell(k, s[0]+offset, k, pos+offset)
+					colors[k*n+pos+offset] = swapColor(c, swap && k%2 == 1)
+				}
+			}
+		}
+	}
+
+	for k := 0; k < 4; k++ {
+		next := (k + 1) % 4
+		for p := 0; p < g.Size; p++ {
+			a := k*n + p + offset
+			b := next*n - p + offset
+			if colors[a] == colors[b] {
+				uf.Union(a, b)
+			} else if overlap {
+				return false
+			}
+		}
+	}
+	return oneRegionPerColor(uf, colors)
+}
@@ -0,0 +1,177 @@
+package equiv
+
+import (
+	"github.com/leanovate/gopter"
+	"github.com/leanovate/gopter/gen"
+	"github.com/leanovate/gopter/prop"
+	"testing"
+)
+
+// symmetricGrid fills an n x n grid so that it is fixed by turn
+// (with the colors swapped if swap), taking each orbit's color from
+// the random grid.  It returns nil if that is impossible.
+func symmetricGrid(n int, random [][]int, turn func(x, y int) (int, int), swap bool) [][]int {
+	grid := make([][]int, n)
+	for y := range grid {
+		grid[y] = make([]int, n)
+		for x := range grid[y] {
+			grid[y][x] = -1
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if grid[y][x] != -1 {
+				continue
+			}
+			c := random[y][x]
+			for i, j := x, y; grid[j][i] == -1; i, j = turn(i, j) {
+				grid[j][i] = c
+				c = swapColor(c, swap)
+			}
+			// Back where we started, with the wrong color?
+			if grid[y][x] != c {
+				return nil
+			}
+		}
+	}
+	return grid
+}
+
+// twoRegions checks the whole grid by DFS.
+func twoRegions(n int, grid [][]int) bool {
+	colors := FlattenGrid(n, grid)
+	visited := make([]bool, n*n)
+	numComponents := make([]int, 2)
+	for i, c := range colors {
+		if len(ConnectedComponentDFS(n, colors, Coord{i%n + 1, i/n + 1}, visited)) > 0 {
+			numComponents[c] += 1
+		}
+	}
+	return numComponents[0] == 1 && numComponents[1] == 1
+}
+
+// hasFinishedRegion checks whether the top-left width x height corner
+// of the grid has a region not touching its bottom row or right column,
+// which the classes do not record.
+func hasFinishedRegion(width int, height int, grid [][]int, right bool) bool {
+	board := Board{Width: width, Height: height}
+	colors := make([]int, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			colors[y*width+x] = grid[y][x]
+		}
+	}
+	visited := make([]bool, width*height)
+	for y := 1; y <= height; y++ {
+		for x := 1; x <= width; x++ {
+			component := board.ConnectedComponent(colors, Coord{x, y}, visited)
+			if len(component) == 0 {
+				continue
+			}
+			touches := false
+			for _, c := range component {
+				if c.Y == height || (right && c.X == width) {
+					touches = true
+				}
+			}
+			if !touches {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkHalfTurn(t *testing.T, n int, swap bool) {
+	halfTurn := func(x, y int) (int, int) {
+		return n - 1 - x, n - 1 - y
+	}
+	height := (n + 1) / 2
+	overlap := n%2 == 1
+
+	invariant := func(random [][]int) bool {
+		grid := symmetricGrid(n, random, halfTurn, swap)
+		expected := false
+		if grid == nil {
+			// Use the random grid instead, which cannot work
+			grid = random
+		} else {
+			if hasFinishedRegion(n, height, grid, false) {
+				return true
+			}
+			expected = twoRegions(n, grid)
+		}
+		class := RectangleClassForGrid(n, height, grid)
+		class.MakeCanonical()
+		if class.HalfTurnValid(swap, overlap) != expected {
+			t.Logf("Grid:\n%v", ShowGrid(n, grid))
+			t.Logf("Class %v expected %v", class.Key(), expected)
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("half turn matches DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(n, gen.SliceOfN(n, gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestGridRectangle_HalfTurn(t *testing.T) {
+	checkHalfTurn(t, 4, false)
+	checkHalfTurn(t, 5, false)
+}
+
+func TestGridRectangle_HalfTurnSwap(t *testing.T) {
+	checkHalfTurn(t, 4, true)
+	checkHalfTurn(t, 5, true)
+}
+
+func checkQuarterTurn(t *testing.T, n int, swap bool) {
+	quarterTurn := func(x, y int) (int, int) {
+		return n - 1 - y, x
+	}
+	size := (n + 1) / 2
+	overlap := n%2 == 1
+
+	invariant := func(random [][]int) bool {
+		grid := symmetricGrid(n, random, quarterTurn, swap)
+		expected := false
+		if grid == nil {
+			// Use the random grid instead, which cannot work
+			grid = random
+		} else {
+			if hasFinishedRegion(size, size, grid, true) {
+				return true
+			}
+			expected = twoRegions(n, grid)
+		}
+		class := EdgeClassForGrid(size, grid)
+		class.MakeCanonical()
+		if class.QuarterTurnValid(swap, overlap) != expected {
+			t.Logf("Grid:\n%v", ShowGrid(n, grid))
+			t.Logf("Class %v expected %v", class.Key(), expected)
+			return false
+		}
+		return true
+	}
+
+	properties := gopter.NewProperties(nil)
+	properties.Property("quarter turn matches DFS",
+		prop.ForAll(invariant,
+			gen.SliceOfN(n, gen.SliceOfN(n, gen.IntRange(0, 1))),
+		))
+	properties.TestingRun(t)
+}
+
+func TestGridBoundary_QuarterTurn(t *testing.T) {
+	checkQuarterTurn(t, 4, false)
+	checkQuarterTurn(t, 5, false)
+}
+
+func TestGridBoundary_QuarterTurnSwap(t *testing.T) {
+	checkQuarterTurn(t, 4, true)
+	checkQuarterTurn(t, 6, true)
+}
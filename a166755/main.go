@@ -3,29 +3,296 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math/big"
 	"os"
 	"runtime/pprof"
-	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/mgritter/oeis/a166755/enumerate"
+	"github.com/mgritter/oeis/a166755/equiv"
 
 	"net/http"
 	_ "net/http/pprof"
 )
 
-var NumWorkers = flag.Int("numworkers", 8, "number of worker goroutines")
+var NumWorkers = flag.Int("numworkers", enumerate.DefaultNumWorkers, "number of worker goroutines")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
 var RunExhaustive = flag.Bool("exhaustive", false, "use exhaustive enumeration")
 var RunSquare = flag.Bool("square", false, "use expanding squres")
 var Verbose = flag.Bool("verbose", false, "verbose output")
+var NumColors = flag.Int("colors", 2, "number of colors, each of which must form one region")
+var CountRegions = flag.Bool("regions", false, "count all grids by their number of regions")
+var MaxRegions = flag.Int("maxregions", 0, "with -regions, only count grids with at most this many regions")
+var BoundaryName = flag.String("boundary", "plain", "plain, cylinder (left and right sides adjacent) or torus")
+var TableFile = flag.String("table", "", "write the count for every rectangle computed to a CSV file")
+var LatticeName = flag.String("lattice", "square", "square, hexagonal or triangular, for an n x n rhombus, or cubic, for an n x n x n cube")
+var CountSymmetric = flag.Bool("symmetric", false, "count square grids fixed by each rotation and reflection, with or without swapping colors")
+var AdjacencyName = flag.String("adjacency", "4", "4 or 8-connectivity, or a comma-separated value for each color, starting with white")
+var CountCells = flag.Bool("cells", false, "count the grids by their number of black cells")
+var CountInterface = flag.Bool("interface", false, "count the grids by the length of the interface between colors")
+var NumSamples = flag.Int("sample", 0, "print this many grids with two regions, chosen uniformly at random, for each size")
+var Seed = flag.Int64("seed", 1, "random seed for -sample")
+var RankFile = flag.String("rank", "", "print the rank of each two-region grid in this file, drawn with . and X")
+var UnrankList = flag.String("unrank", "", "print the two-region grids at these comma-separated ranks or ranges, such as 0-9,100")
+var ListFile = flag.String("list", "", "write every two-region grid to this file, drawn with . and X")
+var Modular = flag.Bool("modular", false, "count modulo several primes, and reconstruct the counts by the Chinese Remainder Theorem")
+var UseProfile = flag.Bool("profile", false, "count with the broken-profile transfer, adding one cell at a time instead of a whole row")
+var CheckpointFile = flag.String("checkpoint", "", "after each height of the rectangle enumeration, save its state for width w to this file, with .w appended")
+var ResumeFile = flag.String("resume", "", "continue the rectangle enumeration from this checkpoint")
+var DiskDir = flag.String("disk", "", "keep the class counts and successors of the rectangle enumeration in sorted files in a temporary directory here")
+var MemoryBudget = flag.Int("memory", 1024, "with -disk, the approximate number of megabytes of counts or successors to hold in memory")
+var VerifyLimit = flag.Int("verify", 0, "check that -exhaustive, -square, the rectangle enumeration and -profile agree for every n x n grid up to this size, exiting with status 1 if not")
+var AuditLimit = flag.Int("audit", 0, "compare the count of every class kept by -square and the rectangle enumeration with a direct classification of every grid, up to this size, exiting with status 1 if any differ")
+var BFiles = flag.String("bfile", "", "comma-separated OEIS b-files, such as b166755.txt, with more known terms to check against")
+var OutputFormat = flag.String("format", "text", "write the counts as text, bfile (an OEIS b-file of the n x n grids), jsonl (JSON Lines) or csv")
+var OutputFile = flag.String("output", "", "write the counts to this file instead of standard output")
+var ConstraintName = flag.String("constraints", "connected", "connected, unrestricted or absent, or a comma-separated value for each color, starting with white")
+
+// parseRange parses either "n" or "a-b".
+func parseRange(txt string) ([]int, error) {
+	bounds := strings.SplitN(txt, "-", 2)
+	lo, err := strconv.ParseInt(bounds[0], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	hi := lo
+	if len(bounds) == 2 {
+		hi, err = strconv.ParseInt(bounds[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if lo < 1 || hi < lo {
+		return nil, fmt.Errorf("bad range %v", txt)
+	}
+	ret := make([]int, 0, hi-lo+1)
+	for i := lo; i <= hi; i++ {
+		ret = append(ret, int(i))
+	}
+	return ret, nil
+}
+
+// parsePositions parses a comma-separated list of numbers or ranges
+// "a-b", which may be too large for an int.
+func parsePositions(txt string) ([]*big.Int, error) {
+	ret := make([]*big.Int, 0)
+	for _, part := range strings.Split(txt, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		lo, ok := new(big.Int).SetString(bounds[0], 10)
+		if !ok {
+			return nil, fmt.Errorf("bad number %v", bounds[0])
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			hi, ok = new(big.Int).SetString(bounds[1], 10)
+			if !ok {
+				return nil, fmt.Errorf("bad number %v", bounds[1])
+			}
+		}
+		if hi.Cmp(lo) < 0 {
+			return nil, fmt.Errorf("bad range %v", part)
+		}
+		for i := new(big.Int).Set(lo); i.Cmp(hi) <= 0; i = new(big.Int).Add(i, big.NewInt(1)) {
+			ret = append(ret, i)
+		}
+	}
+	return ret, nil
+}
+
+// parseDimensions accepts "n", "a-b", "mxn", or ranges on either side
+// such as "5x3-9" or "2-4x2-4".  A single number means a square.
+func parseDimensions(txt string) ([]enumerate.Dimensions, error) {
+	sides := strings.SplitN(txt, "x", 2)
+	widths, err := parseRange(sides[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(sides) == 1 {
+		ret := make([]enumerate.Dimensions, len(widths))
+		for i, n := range widths {
+			ret[i] = enumerate.Dimensions{n, n}
+		}
+		return ret, nil
+	}
+	heights, err := parseRange(sides[1])
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]enumerate.Dimensions, 0, len(widths)*len(heights))
+	for _, w := range widths {
+		for _, h := range heights {
+			ret = append(ret, enumerate.Dimensions{w, h})
+		}
+	}
+	return ret, nil
+}
+
+// parseAdjacency parses either one adjacency for all colors, or
+// a list with one per color.
+func parseAdjacency(txt string, numColors int) ([]equiv.Adjacency, error) {
+	names := strings.Split(txt, ",")
+	if len(names) != 1 && len(names) != numColors {
+		return nil, fmt.Errorf("expected 1 or %d values", numColors)
+	}
+	ret := make([]equiv.Adjacency, numColors)
+	for c := range ret {
+		name := names[0]
+		if len(names) > 1 {
+			name = names[c]
+		}
+		switch name {
+		case "4":
+			ret[c] = equiv.Four
+		case "8":
+			ret[c] = equiv.Eight
+		default:
+			return nil, fmt.Errorf("unknown adjacency %v", name)
+		}
+	}
+	return ret, nil
+}
+
+// parseConstraints parses either one constraint for all colors, or
+// a list with one per color.
+func parseConstraints(txt string, numColors int) ([]equiv.Constraint, error) {
+	names := strings.Split(txt, ",")
+	if len(names) != 1 && len(names) != numColors {
+		return nil, fmt.Errorf("expected 1 or %d values", numColors)
+	}
+	ret := make([]equiv.Constraint, numColors)
+	for c := range ret {
+		name := names[0]
+		if len(names) > 1 {
+			name = names[c]
+		}
+		switch name {
+		case "connected":
+			ret[c] = equiv.Connected
+		case "unrestricted":
+			ret[c] = equiv.Unrestricted
+		case "absent":
+			ret[c] = equiv.Absent
+		default:
+			return nil, fmt.Errorf("unknown constraint %v", name)
+		}
+	}
+	return ret, nil
+}
+
+// parseRules builds the Rules from the flags.
+func parseRules() (*equiv.Rules, error) {
+	if *NumColors < 1 {
+		return nil, fmt.Errorf("-colors must be at least 1")
+	}
+
+	var boundary equiv.Boundary
+	switch *BoundaryName {
+	case "plain":
+		boundary = equiv.Plain
+	case "cylinder":
+		boundary = equiv.Cylinder
+	case "torus":
+		boundary = equiv.Torus
+	default:
+		return nil, fmt.Errorf("unknown boundary %v", *BoundaryName)
+	}
+
+	var lattice equiv.Lattice
+	switch *LatticeName {
+	case "square":
+		lattice = equiv.Square
+	case "hexagonal":
+		lattice = equiv.Hexagonal
+	case "triangular":
+		lattice = equiv.Triangular
+	case "cubic":
+		lattice = equiv.Cubic
+	default:
+		return nil, fmt.Errorf("unknown lattice %v", *LatticeName)
+	}
+
+	adjacency, err := parseAdjacency(*AdjacencyName, *NumColors)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse adjacency %v: %v", *AdjacencyName, err)
+	}
+	constraintName := *ConstraintName
+	if *CountRegions && !isSet("constraints") {
+		// Every color may form any number of regions.
+		constraintName = "unrestricted"
+	}
+	constraints, err := parseConstraints(constraintName, *NumColors)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse constraints %v: %v", constraintName, err)
+	}
+	return &equiv.Rules{
+		NumColors:    *NumColors,
+		Boundary:     boundary,
+		Lattice:      lattice,
+		Adjacency:    adjacency,
+		Constraints:  constraints,
+		CountRegions: *CountRegions,
+		CountBlack:   *CountCells,
+	}, nil
+}
+
+// isSet reports whether the flag was given on the command line.
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// chosenMode returns the flag which chooses what to do instead of
+// counting, if any; only one may be given.
+func chosenMode() (string, error) {
+	modes := []struct {
+		Name   string
+		Chosen bool
+	}{
+		{"-exhaustive", *RunExhaustive},
+		{"-square", *RunSquare},
+		{"-sample", *NumSamples > 0},
+		{"-rank", *RankFile != ""},
+		{"-unrank", *UnrankList != ""},
+		{"-list", *ListFile != ""},
+		{"-verify", *VerifyLimit != 0},
+		{"-audit", *AuditLimit != 0},
+	}
+	chosen := []string{}
+	for _, m := range modes {
+		if m.Chosen {
+			chosen = append(chosen, m.Name)
+		}
+	}
+	switch len(chosen) {
+	case 0:
+		return "", nil
+	case 1:
+		return chosen[0], nil
+	}
+	return "", fmt.Errorf("%v cannot be used together", strings.Join(chosen, ", "))
+}
 
 func main() {
 	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
 
+// run counts the grids described by the flags, returning any error
+// once the output is closed.
+func run() (err error) {
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			return
+			return err
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
@@ -33,30 +300,111 @@ func main() {
 
 	go http.ListenAndServe("localhost:5432", nil)
 
-	cases := []int{2, 3, 4, 5, 6, 7, 8, 9, 10}
+	cases := []enumerate.Dimensions{}
+	for n := 2; n <= 10; n++ {
+		cases = append(cases, enumerate.Dimensions{n, n})
+	}
 	if len(flag.Args()) > 0 {
-		cases = []int{}
+		cases = []enumerate.Dimensions{}
 		for _, txt := range flag.Args() {
-			n, err := strconv.ParseInt(txt, 10, 32)
+			dims, err := parseDimensions(txt)
 			if err != nil {
-				fmt.Printf("couldn't parse argument %v: %v\n", txt, err)
-				return
+				return fmt.Errorf("couldn't parse argument %v: %v", txt, err)
 			}
-			cases = append(cases, int(n))
+			cases = append(cases, dims...)
 		}
 	}
 
-	if *RunExhaustive {
-		exhaustiveEnumeration(cases)
-		return
+	mode, err := chosenMode()
+	if err != nil {
+		return err
+	}
+	rules, err := parseRules()
+	if err != nil {
+		return err
+	}
+	opts := enumerate.Options{
+		NumWorkers:   *NumWorkers,
+		Verbose:      *Verbose,
+		Progress:     os.Stdout,
+		Rules:        rules,
+		Weights:      enumerate.Weights{Cells: *CountCells, Interface: *CountInterface},
+		MaxRegions:   *MaxRegions,
+		Symmetric:    *CountSymmetric,
+		Profile:      *UseProfile,
+		Modular:      *Modular,
+		DiskDir:      *DiskDir,
+		MemoryBudget: *MemoryBudget << 20,
+		Checkpoint:   *CheckpointFile,
+		Resume:       *ResumeFile,
+	}
+	if *OutputFormat != "text" {
+		// Keep the progress out of the results.
+		opts.Progress = os.Stderr
+	}
+	if *BFiles != "" {
+		for _, filename := range strings.Split(*BFiles, ",") {
+			if err := loadBFile(filename); err != nil {
+				return fmt.Errorf("couldn't read b-file %v: %v", filename, err)
+			}
+		}
+	}
+	if opts.TwoColors() && !*CountRegions && !*CountSymmetric {
+		CheckedSequence = "A166755"
 	}
 
-	if *RunSquare {
-		// This is a bit silly, we have to generate all smaller cases anyway.
-		sort.Ints(cases)
-		equivalenceClassEnumeration(cases)
-		return
+	switch mode {
+	case "-audit":
+		ok, err := enumerate.AuditEnumeration(os.Stdout, *AuditLimit, opts)
+		if err == nil && !ok {
+			err = fmt.Errorf("-audit found class counts which differ")
+		}
+		return err
+	case "-verify":
+		ok, err := enumerate.VerifyEnumeration(os.Stdout, *VerifyLimit, opts)
+		if err == nil && !ok {
+			err = fmt.Errorf("-verify found methods which disagree")
+		}
+		return err
+	case "-list":
+		return enumerate.ListEnumeration(os.Stdout, cases, *ListFile, opts)
+	case "-rank":
+		return enumerate.RankEnumeration(os.Stdout, *RankFile, opts)
+	case "-unrank":
+		positions, err := parsePositions(*UnrankList)
+		if err != nil {
+			return fmt.Errorf("couldn't parse -unrank %v: %v", *UnrankList, err)
+		}
+		return enumerate.UnrankEnumeration(os.Stdout, cases, positions, opts)
+	case "-sample":
+		return enumerate.SampleEnumeration(os.Stdout, cases, *NumSamples, *Seed, opts)
 	}
 
-	rectangleEnumeration(cases)
+	out, err := openReporter(*OutputFormat, *OutputFile)
+	if err != nil {
+		return err
+	}
+	if *TableFile != "" {
+		table, err := openReporter("csv", *TableFile)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		out = multiReporter{out, table}
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	switch {
+	case mode == "-exhaustive":
+		return enumerate.ExhaustiveEnumeration(cases, opts, out)
+	case mode == "-square":
+		return enumerate.EquivalenceClassEnumeration(cases, opts, out)
+	case *CountSymmetric:
+		return enumerate.SymmetricEnumeration(cases, opts, out)
+	}
+	return enumerate.RectangleEnumeration(cases, opts, out)
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// knownTerms are the terms of each OEIS sequence already established,
+// by n.  Terms from b-files are added by loadBFile.
+var knownTerms = map[string]map[int]string{
+	// Number of n x n grids of two colors with exactly two regions
+	"A166755": {
+		1:  "0",
+		2:  "12",
+		3:  "106",
+		4:  "1254",
+		5:  "32426",
+		6:  "2247486",
+		7:  "443968782",
+		8:  "255122769986",
+		9:  "431534126902662",
+		10: "2165656440779563158",
+	},
+}
+
+// CheckedSequence is the sequence which the counts of n x n grids
+// should match, or "" if the options do not correspond to one.
+var CheckedSequence string
+
+// readBFile reads an OEIS b-file: lines of n and a(n), with comments
+// starting with #.
+func readBFile(filename string) (map[int]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	terms := make(map[int]string)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected n and a(n)", lineNumber)
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		if _, ok := new(big.Int).SetString(fields[1], 10); !ok {
+			return nil, fmt.Errorf("line %d: %q is not a number", lineNumber, fields[1])
+		}
+		terms[n] = fields[1]
+	}
+	return terms, scanner.Err()
+}
+
+var bFileName = regexp.MustCompile(`^b([0-9]{6})\.txt$`)
+
+// loadBFile adds the terms of a b-file, named bNNNNNN.txt as on the
+// OEIS, to those known for sequence ANNNNNN.  It returns an error if
+// they disagree with any term already known.
+func loadBFile(filename string) error {
+	match := bFileName.FindStringSubmatch(filepath.Base(filename))
+	if match == nil {
+		return fmt.Errorf("expected a name like b166755.txt")
+	}
+	sequence := "A" + match[1]
+	terms, err := readBFile(filename)
+	if err != nil {
+		return err
+	}
+	known, ok := knownTerms[sequence]
+	if !ok {
+		known = make(map[int]string)
+		knownTerms[sequence] = known
+	}
+	for n, term := range terms {
+		if exist, ok := known[n]; ok && exist != term {
+			return fmt.Errorf("a(%d) = %v, but %v is already known", n, term, exist)
+		}
+		known[n] = term
+	}
+	return nil
+}
+
+// termStatus compares a count of n x n grids with CheckedSequence,
+// returning "matches", "new" or "MISMATCH", or "" if there is no
+// sequence to check.
+func termStatus(n int, count *big.Int) string {
+	if CheckedSequence == "" {
+		return ""
+	}
+	term, ok := knownTerms[CheckedSequence][n]
+	if !ok {
+		return "new"
+	}
+	if term != count.String() {
+		return fmt.Sprintf("MISMATCH (expected %v)", term)
+	}
+	return "matches"
+}
+
+// showStatus formats termStatus to follow the rest of a line.
+func showStatus(n int, count *big.Int) string {
+	status := termStatus(n, count)
+	if status == "" {
+		return ""
+	}
+	return fmt.Sprintf(" | %v %v", CheckedSequence, status)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeBFile writes the contents to a file with the given name in a
+// new directory.
+func writeBFile(t *testing.T, name string, contents string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestReadBFile(t *testing.T) {
+	filename := writeBFile(t, "b166755.txt", `# A166755
+# Number of n x n grids with exactly two regions
+
+1 0
+2 12
+  3   106  
+
+# a gap, then a large term
+10 2165656440779563158
+`)
+	terms, err := readBFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]string{1: "0", 2: "12", 3: "106", 10: "2165656440779563158"}
+	if !reflect.DeepEqual(terms, expected) {
+		t.Errorf("read %v, expected %v", terms, expected)
+	}
+}
+
+func TestReadBFile_Malformed(t *testing.T) {
+	for _, contents := range []string{
+		"1 0\n2\n",
+		"1 0\n2 12 3\n",
+		"one 0\n",
+		"1 12x\n",
+		"1.5 12\n",
+	} {
+		filename := writeBFile(t, "b166755.txt", contents)
+		if terms, err := readBFile(filename); err == nil {
+			t.Errorf("expected an error from %q, read %v", contents, terms)
+		}
+	}
+	if _, err := readBFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("expected an error from a missing file")
+	}
+}
+
+func TestLoadBFile(t *testing.T) {
+	defer delete(knownTerms, "A000001")
+	filename := writeBFile(t, "b000001.txt", "1 1\n4 2\n")
+	if err := loadBFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[int]string{1: "1", 4: "2"}
+	if !reflect.DeepEqual(knownTerms["A000001"], expected) {
+		t.Errorf("loaded %v, expected %v", knownTerms["A000001"], expected)
+	}
+
+	// Agreeing terms may be loaded again, but not conflicting ones.
+	if err := loadBFile(writeBFile(t, "b000001.txt", "4 2\n5 5\n")); err != nil {
+		t.Errorf("couldn't load agreeing terms: %v", err)
+	}
+	if err := loadBFile(writeBFile(t, "b000001.txt", "4 3\n")); err == nil {
+		t.Errorf("expected an error loading a conflicting term")
+	}
+	if err := loadBFile(writeBFile(t, "b166755.txt", "2 13\n")); err == nil {
+		t.Errorf("expected an error from a term of A166755 which is wrong")
+	}
+	if err := loadBFile(writeBFile(t, "terms.txt", "1 1\n")); err == nil {
+		t.Errorf("expected an error from a file not named like a b-file")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/mgritter/oeis/a166755/enumerate"
+)
+
+// openReporter writes results in the format to a new file, or to
+// standard output if filename is empty.
+func openReporter(format string, filename string) (enumerate.Reporter, error) {
+	if filename == "" {
+		return newReporter(format, os.Stdout)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReporter(format, f)
+	if err != nil {
+		f.Close()
+		os.Remove(filename)
+		return nil, err
+	}
+	return fileReporter{r, f}, nil
+}
+
+// newReporter writes results in the format to w.
+func newReporter(format string, w io.Writer) (enumerate.Reporter, error) {
+	switch format {
+	case "text":
+		return textReporter{w}, nil
+	case "bfile":
+		return bFileReporter{w}, nil
+	case "jsonl":
+		return jsonReporter{json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvReporter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %v", format)
+}
+
+// textReporter writes the same lines as each method always has.
+type textReporter struct {
+	w io.Writer
+}
+
+func (t textReporter) Progress(r enumerate.Result) {
+	fmt.Fprintf(t.w, " T(%d,%d) = %v\n", r.Width, r.Height, r.Count)
+}
+
+func (t textReporter) Report(r enumerate.Result) {
+	switch {
+	case r.Fixed != nil:
+		fmt.Fprintf(t.w, "**** N=%d | orbits=%v\n", r.Width, r.Count)
+		for i, sym := range enumerate.Symmetries {
+			fmt.Fprintf(t.w, "  %v | %v\n", sym, r.Fixed[i])
+		}
+		fmt.Fprintf(t.w, "\n")
+
+	case r.Method == "exhaustive":
+		if r.Dimensions().IsSquare() {
+			fmt.Fprintf(t.w, "%d | %v | %v%v\n", r.Width, r.Count, r.NotValid, showStatus(r.Width, r.Count))
+		} else {
+			fmt.Fprintf(t.w, "%v | %v | %v\n", r.Dimensions(), r.Count, r.NotValid)
+		}
+		for n, count := range r.ByRegions {
+			if count.Sign() != 0 {
+				fmt.Fprintf(t.w, "  regions=%d | grids=%v\n", n, count)
+			}
+		}
+		if r.Distribution != nil {
+			writeDistribution(t.w, r.Weights, r.Distribution)
+		}
+
+	case r.Method == "square":
+		fmt.Fprintf(t.w, "\n N=%d | grids=%v | classes=%v%v \n\n", r.Width, r.Count, r.NumClasses, showStatus(r.Width, r.Count))
+
+	default:
+		if r.Dimensions().IsSquare() {
+			fmt.Fprintf(t.w, "**** N=%v | grids=%v | classes = %v%v \n\n", r.Width, r.Count, r.NumClasses, showStatus(r.Width, r.Count))
+		} else {
+			fmt.Fprintf(t.w, "**** %v | grids=%v | classes = %v \n\n", r.Dimensions(), r.Count, r.NumClasses)
+		}
+		for n, count := range r.ByRegions {
+			if count.Sign() != 0 {
+				fmt.Fprintf(t.w, "  regions=%d | grids=%v\n", n, count)
+			}
+		}
+		if r.ByRegions != nil {
+			fmt.Fprintf(t.w, "\n")
+		}
+		if r.Distribution != nil {
+			writeDistribution(t.w, r.Weights, r.Distribution)
+			fmt.Fprintf(t.w, "\n")
+		}
+	}
+}
+
+func (t textReporter) Close() error {
+	return nil
+}
+
+// bFileReporter writes the count of each n x n grid as an OEIS b-file,
+// skipping other rectangles.  With -symmetric, the count is the number
+// of orbits.
+type bFileReporter struct {
+	w io.Writer
+}
+
+func (b bFileReporter) Progress(r enumerate.Result) {}
+
+func (b bFileReporter) Report(r enumerate.Result) {
+	if r.Dimensions().IsSquare() {
+		fmt.Fprintf(b.w, "%d %v\n", r.Width, r.Count)
+	}
+}
+
+func (b bFileReporter) Close() error {
+	return nil
+}
+
+// jsonResult is one line written by jsonReporter.
+type jsonResult struct {
+	Method string `json:"method"`
+
+	// Only for n x n grids
+	N int `json:"n,omitempty"`
+
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Count   *big.Int `json:"count"`
+	Classes int      `json:"classes,omitempty"`
+
+	// In seconds
+	Elapsed float64 `json:"elapsed"`
+
+	// The count by number of regions, with -regions
+	Regions []*big.Int `json:"regions,omitempty"`
+
+	// The number of grids fixed by each symmetry, with -symmetric,
+	// when the count is the number of orbits
+	Fixed map[string]*big.Int `json:"fixed,omitempty"`
+
+	// Compared with the known terms; see termStatus
+	Status string `json:"status,omitempty"`
+}
+
+// jsonReporter writes a JSON object for each size requested.
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+func (j jsonReporter) Progress(r enumerate.Result) {}
+
+func (j jsonReporter) Report(r enumerate.Result) {
+	jr := jsonResult{
+		Method:  r.Method,
+		Width:   r.Width,
+		Height:  r.Height,
+		Count:   r.Count,
+		Classes: r.NumClasses,
+		Elapsed: r.Elapsed.Seconds(),
+		Regions: r.ByRegions,
+	}
+	if r.Dimensions().IsSquare() {
+		jr.N = r.Width
+		if r.Fixed == nil {
+			jr.Status = termStatus(r.Width, r.Count)
+		}
+	}
+	if r.Fixed != nil {
+		jr.Fixed = make(map[string]*big.Int)
+		for i, sym := range enumerate.Symmetries {
+			jr.Fixed[sym.String()] = r.Fixed[i]
+		}
+	}
+	j.enc.Encode(jr)
+}
+
+func (j jsonReporter) Close() error {
+	return nil
+}
+
+// csvReporter writes a table of every rectangle counted.  The columns
+// depend on the first result: with -regions, one row for each number
+// of regions, with -cells or -interface, one row for each term of
+// the distribution, and with -symmetric, one row for each symmetry.
+type csvReporter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvReporter) Progress(r enumerate.Result) {
+	c.write(r)
+}
+
+// Report writes the sizes requested from methods without Progress.
+func (c *csvReporter) Report(r enumerate.Result) {
+	if r.Method == "exhaustive" || r.Method == "square" || r.Method == "symmetric" {
+		c.write(r)
+	}
+}
+
+func (c *csvReporter) write(r enumerate.Result) {
+	width := strconv.Itoa(r.Width)
+	height := strconv.Itoa(r.Height)
+	switch {
+	case r.Fixed != nil:
+		if !c.header {
+			c.w.Write([]string{"width", "height", "symmetry", "count"})
+		}
+		for i, sym := range enumerate.Symmetries {
+			c.w.Write([]string{width, height, sym.String(), r.Fixed[i].String()})
+		}
+	case r.ByRegions != nil:
+		if !c.header {
+			c.w.Write([]string{"width", "height", "regions", "count"})
+		}
+		for n, count := range r.ByRegions {
+			c.w.Write([]string{width, height, strconv.Itoa(n), count.String()})
+		}
+	case r.Distribution != nil:
+		if !c.header {
+			c.w.Write([]string{"width", "height", "cells", "interface", "count"})
+		}
+		r.Distribution.Terms(func(k int, m int, count *big.Int) {
+			c.w.Write([]string{width, height, strconv.Itoa(k), strconv.Itoa(m), count.String()})
+		})
+	default:
+		if !c.header {
+			c.w.Write([]string{"width", "height", "count", "classes"})
+		}
+		c.w.Write([]string{width, height, r.Count.String(), strconv.Itoa(r.NumClasses)})
+	}
+	c.header = true
+	c.w.Flush()
+}
+
+func (c *csvReporter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// fileReporter closes the file written by another Reporter.
+type fileReporter struct {
+	enumerate.Reporter
+	f *os.File
+}
+
+func (fr fileReporter) Close() error {
+	err := fr.Reporter.Close()
+	if closeErr := fr.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// multiReporter sends every result to each of several Reporters.
+type multiReporter []enumerate.Reporter
+
+func (m multiReporter) Progress(r enumerate.Result) {
+	for _, out := range m {
+		out.Progress(r)
+	}
+}
+
+func (m multiReporter) Report(r enumerate.Result) {
+	for _, out := range m {
+		out.Report(r)
+	}
+}
+
+func (m multiReporter) Close() error {
+	var ret error
+	for _, out := range m {
+		if err := out.Close(); ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// writeDistribution shows each term of p, labeled by the statistics
+// being counted.
+func writeDistribution(out io.Writer, w enumerate.Weights, p enumerate.Polynomial) {
+	p.Terms(func(k int, m int, c *big.Int) {
+		switch {
+		case w.Cells && w.Interface:
+			fmt.Fprintf(out, "  cells=%d | interface=%d | grids=%v\n", k, m, c)
+		case w.Cells:
+			fmt.Fprintf(out, "  cells=%d | grids=%v\n", k, c)
+		case w.Interface:
+			fmt.Fprintf(out, "  interface=%d | grids=%v\n", m, c)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mgritter/oeis/a166755/enumerate"
+)
+
+// rectangleRun reports the counts of the rectangle enumeration of 2x2
+// and 2x3, with a fixed elapsed time.
+func rectangleRun(out enumerate.Reporter) {
+	result := func(w int, h int, count int64, classes int) enumerate.Result {
+		return enumerate.Result{
+			HeightResult: enumerate.HeightResult{Width: w, Height: h, Count: big.NewInt(count), NumClasses: classes},
+			Method:       "rectangle",
+			Elapsed:      1500 * time.Millisecond,
+		}
+	}
+	out.Progress(result(2, 1, 2, 2))
+	out.Progress(result(2, 2, 12, 3))
+	out.Progress(result(2, 3, 30, 3))
+	out.Report(result(2, 2, 12, 3))
+	out.Report(result(2, 3, 30, 3))
+}
+
+// symmetricRun is the count of 3x3 grids fixed by each symmetry.
+func symmetricRun(out enumerate.Reporter) {
+	fixed := make([]*big.Int, len(enumerate.Symmetries))
+	for i, n := range []int64{106, 2, 2, 2, 18, 18, 14, 14} {
+		fixed[i] = big.NewInt(n)
+	}
+	for i := 8; i < len(fixed); i++ {
+		fixed[i] = big.NewInt(0)
+	}
+	out.Report(enumerate.Result{
+		HeightResult: enumerate.HeightResult{Width: 3, Height: 3, Count: big.NewInt(11)},
+		Method:       "symmetric",
+		Fixed:        fixed,
+		Elapsed:      250 * time.Millisecond,
+	})
+}
+
+// checkGolden compares everything written in the format by run with
+// the expected output.
+func checkGolden(t *testing.T, format string, run func(enumerate.Reporter), expected string) {
+	saved := CheckedSequence
+	CheckedSequence = "A166755"
+	defer func() { CheckedSequence = saved }()
+
+	var buf bytes.Buffer
+	out, err := newReporter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(out)
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if actual := buf.String(); actual != expected {
+		t.Errorf("%v output:\n%v\nexpected:\n%v", format, actual, expected)
+	}
+}
+
+const symmetricText = `**** N=3 | orbits=11
+  identity | 106
+  rotate90 | 2
+  rotate180 | 2
+  rotate270 | 2
+  flipX | 18
+  flipY | 18
+  diagonal | 14
+  antidiagonal | 14
+  identity+swap | 0
+  rotate90+swap | 0
+  rotate180+swap | 0
+  rotate270+swap | 0
+  flipX+swap | 0
+  flipY+swap | 0
+  diagonal+swap | 0
+  antidiagonal+swap | 0
+
+`
+
+func TestReporter_Text(t *testing.T) {
+	checkGolden(t, "text", rectangleRun, ` T(2,1) = 2
+ T(2,2) = 12
+ T(2,3) = 30
+**** N=2 | grids=12 | classes = 3 | A166755 matches 
+
+**** 2x3 | grids=30 | classes = 3 
+
+`)
+	checkGolden(t, "text", symmetricRun, symmetricText)
+}
+
+func TestReporter_BFile(t *testing.T) {
+	checkGolden(t, "bfile", rectangleRun, "2 12\n")
+	checkGolden(t, "bfile", symmetricRun, "3 11\n")
+}
+
+func TestReporter_JSONL(t *testing.T) {
+	checkGolden(t, "jsonl", rectangleRun,
+		`{"method":"rectangle","n":2,"width":2,"height":2,"count":12,"classes":3,"elapsed":1.5,"status":"matches"}
+{"method":"rectangle","width":2,"height":3,"count":30,"classes":3,"elapsed":1.5}
+`)
+	checkGolden(t, "jsonl", symmetricRun,
+		`{"method":"symmetric","n":3,"width":3,"height":3,"count":11,"elapsed":0.25,"fixed":{`+
+			`"antidiagonal":14,"antidiagonal+swap":0,"diagonal":14,"diagonal+swap":0,"flipX":18,"flipX+swap":0,`+
+			`"flipY":18,"flipY+swap":0,"identity":106,"identity+swap":0,"rotate180":2,"rotate180+swap":0,`+
+			`"rotate270":2,"rotate270+swap":0,"rotate90":2,"rotate90+swap":0}}
+`)
+}
+
+func TestReporter_CSV(t *testing.T) {
+	checkGolden(t, "csv", rectangleRun, `width,height,count,classes
+2,1,2,2
+2,2,12,3
+2,3,30,3
+`)
+	checkGolden(t, "csv", symmetricRun, `width,height,symmetry,count
+3,3,identity,106
+3,3,rotate90,2
+3,3,rotate180,2
+3,3,rotate270,2
+3,3,flipX,18
+3,3,flipY,18
+3,3,diagonal,14
+3,3,antidiagonal,14
+3,3,identity+swap,0
+3,3,rotate90+swap,0
+3,3,rotate180+swap,0
+3,3,rotate270+swap,0
+3,3,flipX+swap,0
+3,3,flipY+swap,0
+3,3,diagonal+swap,0
+3,3,antidiagonal+swap,0
+`)
+}
+
+func TestReporter_UnknownFormat(t *testing.T) {
+	if _, err := newReporter("xml", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
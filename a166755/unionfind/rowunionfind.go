@@ -10,10 +10,16 @@ type RowUnionFind struct {
 }
 
 func NewRowUnionFind(width int) *RowUnionFind {
+	return NewMultiRowUnionFind(width, 2)
+}
+
+// NewMultiRowUnionFind is like NewRowUnionFind, but allows more than
+// two rows of cells.
+func NewMultiRowUnionFind(width int, rows int) *RowUnionFind {
 	ret := &RowUnionFind{
 		width:  width,
-		parent: make([]int, 2*width),
-		rank:   make([]int, 2*width),
+		parent: make([]int, rows*width),
+		rank:   make([]int, rows*width),
 	}
 	for i := range ret.parent {
 		ret.parent[i] = i
@@ -169,3 +169,23 @@ func TestUnionFind_DoubleRing(t *testing.T) {
 		))
 	properties.TestingRun(t)
 }
+
+func TestRowUnionFind_MultiRow(t *testing.T) {
+	// a b
+	// a a
+	// b a
+	uf := NewMultiRowUnionFind(2, 3)
+	uf.UnionCell(0, 0, 1, 0)
+	uf.UnionCell(1, 0, 1, 1)
+	uf.UnionCell(1, 1, 2, 1)
+
+	if uf.FindCell(0, 0) != uf.FindCell(2, 1) {
+		t.Errorf("(0,0) and (2,1) should be connected")
+	}
+	if uf.FindCell(0, 1) == uf.FindCell(2, 0) {
+		t.Errorf("(0,1) and (2,0) should not be connected")
+	}
+	if uf.FindCell(0, 1) == uf.FindCell(0, 0) {
+		t.Errorf("(0,1) and (0,0) should not be connected")
+	}
+}